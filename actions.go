@@ -0,0 +1,333 @@
+package rpa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+type ActionTypeString string
+
+const (
+	ActionClick    ActionTypeString = "click"
+	ActionInput    ActionTypeString = "input"
+	ActionSelect   ActionTypeString = "select"
+	ActionPress    ActionTypeString = "press"
+	ActionHover    ActionTypeString = "hover"
+	ActionScroll   ActionTypeString = "scroll"
+	ActionWaitShow ActionTypeString = "waitShow"
+	ActionWaitHide ActionTypeString = "waitHide"
+	ActionSleep    ActionTypeString = "sleep"
+	ActionEval     ActionTypeString = "eval"
+)
+
+// Action is a step run on the page before it's crawled, such as opening a tab or expanding a panel.
+//
+// The actions of a CrawlerConfig run after its pageLoad, the actions of a switch case
+// run when the case is matched, before its dataSection is crawled.
+// A failed action is reported in Result.Errors and the following actions still run.
+type Action struct {
+	Type ActionTypeString `json:"type"`
+
+	// Selector of the element of the action, it's required by click, input, select, hover, waitShow and waitHide.
+	// The press action focuses the element and the scroll action scrolls it into view if it's set,
+	// the eval action runs the script with the element as this.
+	Selector string `json:"selector,omitempty"`
+
+	// Value is the text of input, the value or the text of the option of select,
+	// the key of press, such as "Enter" or "Control+a", and the pixels to scroll down without a selector.
+	Value string `json:"value,omitempty"`
+
+	// Script is the JavaScript function body of eval, a returned promise is awaited
+	Script string `json:"script,omitempty"`
+
+	// Timeout is the seconds to wait for the element, CrawlOptions.WaitTimeout by default
+	Timeout int `json:"timeout,omitempty"`
+
+	// Sleep is the milliseconds to wait of sleep, unlike the sleep of PageLoad which is in seconds
+	Sleep int `json:"sleep,omitempty"`
+}
+
+// selectOptionJS selects the option of a <select> by its value or text, and fires the input and change events.
+// It sets the value like setValue of the crawler, with the setter of the prototype which frameworks like React track.
+const selectOptionJS = `function (value) {
+	const options = Array.from(this.options || []);
+	const opt = options.find((o) => o.value === value) || options.find((o) => o.text.trim() === value);
+	if (!opt) {
+		throw new Error('no option ' + JSON.stringify(value));
+	}
+	const setter = Object.getOwnPropertyDescriptor(Object.getPrototypeOf(this), 'value')?.set;
+	if (setter) {
+		setter.call(this, opt.value);
+	} else {
+		this.value = opt.value;
+	}
+	this.dispatchEvent(new Event('input', { bubbles: true }));
+	this.dispatchEvent(new Event('change', { bubbles: true }));
+}`
+
+// keyNames are the names of the keys of the press action
+var keyNames = map[string]input.Key{
+	"Enter":      input.Enter,
+	"Tab":        input.Tab,
+	"Escape":     input.Escape,
+	"Backspace":  input.Backspace,
+	"Delete":     input.Delete,
+	"Space":      input.Space,
+	"ArrowUp":    input.ArrowUp,
+	"ArrowDown":  input.ArrowDown,
+	"ArrowLeft":  input.ArrowLeft,
+	"ArrowRight": input.ArrowRight,
+	"PageUp":     input.PageUp,
+	"PageDown":   input.PageDown,
+	"Home":       input.Home,
+	"End":        input.End,
+	"Control":    input.ControlLeft,
+	"Shift":      input.ShiftLeft,
+	"Alt":        input.AltLeft,
+	"Meta":       input.MetaLeft,
+}
+
+// runActions runs the actions on the page, path is the data path of the actions in the config, such as "actions".
+// The failed actions are returned as CrawlErrors, err is only the cancellation of ctx.
+func runActions(ctx context.Context, page *rod.Page, actions []Action, path string, opts CrawlOptions) (errs []CrawlError, err error) {
+	for i, act := range actions {
+		if ctx.Err() != nil {
+			return errs, ctx.Err()
+		}
+		if aErr := runAction(ctx, page, act, opts); aErr != nil {
+			if ctx.Err() != nil {
+				return errs, ctx.Err()
+			}
+			errs = append(errs, CrawlError{
+				Path:     fmt.Sprintf("%s/%d", path, i),
+				Stage:    StageAction,
+				Selector: act.Selector,
+				Message:  aErr.Error(),
+			})
+			if opts.Strict {
+				return errs, nil
+			}
+		}
+	}
+	return errs, nil
+}
+
+func runAction(ctx context.Context, page *rod.Page, act Action, opts CrawlOptions) error {
+	timeout := opts.WaitTimeout
+	if act.Timeout > 0 {
+		timeout = time.Duration(act.Timeout) * time.Second
+	}
+
+	var el *rod.Element
+	if act.Selector != "" && act.Type != ActionWaitShow && act.Type != ActionWaitHide {
+		var err error
+		if el, err = actionElem(ctx, page, act.Selector, timeout); err != nil {
+			return err
+		}
+	}
+
+	switch act.Type {
+	case ActionClick, ActionInput, ActionSelect, ActionHover:
+		if el == nil {
+			return fmt.Errorf("%s requires a selector", act.Type)
+		}
+	}
+
+	switch act.Type {
+	case ActionClick:
+		return el.Click(proto.InputMouseButtonLeft, 1)
+	case ActionInput:
+		if err := el.SelectAllText(); err != nil {
+			return err
+		}
+		if act.Value == "" {
+			// inputting an empty text leaves the selected text, it's deleted instead
+			if err := el.Focus(); err != nil {
+				return err
+			}
+			return page.Keyboard.Type(input.Backspace)
+		}
+		return el.Input(act.Value)
+	case ActionSelect:
+		_, err := el.Eval(selectOptionJS, act.Value)
+		return err
+	case ActionPress:
+		keys, err := parseKeys(act.Value)
+		if err != nil {
+			return err
+		}
+		if el != nil {
+			if err = el.Focus(); err != nil {
+				return err
+			}
+		}
+		ka := page.KeyActions()
+		if len(keys) > 1 {
+			ka = ka.Press(keys[:len(keys)-1]...)
+		}
+		return ka.Type(keys[len(keys)-1]).Do()
+	case ActionHover:
+		return el.Hover()
+	case ActionScroll:
+		if el != nil {
+			return el.ScrollIntoView()
+		}
+		return scrollDown(page, act.Value)
+	case ActionWaitShow, ActionWaitHide:
+		if act.Selector == "" {
+			return fmt.Errorf("%s requires a selector", act.Type)
+		}
+		return waitElementTimeout(ctx, page, act.Selector, timeout, act.Type == ActionWaitShow)
+	case ActionSleep:
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(act.Sleep) * time.Millisecond):
+		}
+		return nil
+	case ActionEval:
+		script := rod.Eval("function () {\n" + act.Script + "\n}").ByPromise()
+		var err error
+		if el != nil {
+			_, err = el.Evaluate(script)
+		} else {
+			_, err = page.Evaluate(script)
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown action type %q", act.Type)
+	}
+}
+
+// actionElem waits at most timeout for the element of the selector
+func actionElem(ctx context.Context, page *rod.Page, selector string, timeout time.Duration) (*rod.Element, error) {
+	tCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	el, err := QueryElem(page.Context(tCtx), selector)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("no element found after %d seconds", int(timeout.Seconds()))
+		}
+		return nil, err
+	}
+	return el.Context(ctx), nil
+}
+
+// scrollDown scrolls the page down by pixels, or to the bottom if pixels is empty
+func scrollDown(page *rod.Page, pixels string) error {
+	if pixels == "" {
+		_, err := page.Eval(`() => window.scrollTo(0, document.documentElement.scrollHeight)`)
+		return err
+	}
+	y, err := strconv.ParseFloat(pixels, 64)
+	if err != nil {
+		return fmt.Errorf("invalid scroll pixels %q", pixels)
+	}
+	_, err = page.Eval(`(y) => window.scrollBy(0, y)`, y)
+	return err
+}
+
+// parseKeys parses a key of the press action, the keys joined by "+" are pressed together
+func parseKeys(value string) ([]input.Key, error) {
+	if value == "" {
+		return nil, errors.New("press requires a key")
+	}
+	names := strings.Split(value, "+")
+	if strings.HasSuffix(value, "++") || value == "+" {
+		// the "+" key itself, such as "Shift++"
+		names = append(names[:len(names)-2], "+")
+	}
+	keys := make([]input.Key, 0, len(names))
+	for _, name := range names {
+		key, ok := keyNames[name]
+		if !ok {
+			r, size := utf8.DecodeRuneInString(name)
+			if size == 0 || size != len(name) {
+				return nil, fmt.Errorf("unknown key %q", name)
+			}
+			key = input.Key(r)
+			if rod.Try(func() { key.Info() }) != nil {
+				return nil, fmt.Errorf("unknown key %q", name)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// hasCaseActions reports whether a case of the switchSection of cfg has actions
+func hasCaseActions(cfg *CrawlerConfig) bool {
+	if cfg.SwitchSection == nil {
+		return false
+	}
+	for _, c := range cfg.SwitchSection.Cases {
+		if len(c.Actions) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// extractSwitchActions crawls the page like extract, except that the dataSection of a matched case having actions
+// is crawled after its actions run.
+// The switchSection is crawled first without the dataSection of those cases, so the switchRender sees the same data.
+func extractSwitchActions(ctx context.Context, page *rod.Page, cfg *CrawlerConfig, opts CrawlOptions) (*Result, error) {
+	sw := *cfg.SwitchSection
+	sw.Cases = make([]CaseItem, len(cfg.SwitchSection.Cases))
+	for i, c := range cfg.SwitchSection.Cases {
+		if len(c.Actions) > 0 {
+			c.DataSection = DataNodes{}
+		}
+		sw.Cases[i] = c
+	}
+	first := *cfg
+	first.SwitchSection = &sw
+
+	res, err := extract(page, &first, opts.Engine)
+	if err != nil {
+		return nil, err
+	}
+	if res.Data == nil {
+		res.Data = make(DictData)
+	}
+
+	if res.matchedCase == nil || len(cfg.SwitchSection.Cases[*res.matchedCase].Actions) == 0 {
+		return res, nil
+	}
+	i := *res.matchedCase
+	c := cfg.SwitchSection.Cases[i]
+
+	errs, err := runActions(ctx, page, c.Actions, fmt.Sprintf("switchSection/cases/%d/actions", i), opts)
+	res.Errors = append(res.Errors, errs...)
+	if err != nil || (opts.Strict && len(errs) > 0) {
+		return res, err
+	}
+
+	// the downloadSection is kept for the download items of the case, its own result is dropped
+	sub, err := extract(page, &CrawlerConfig{DataSection: c.DataSection, DownloadSection: cfg.DownloadSection}, opts.Engine)
+	if err != nil {
+		return nil, err
+	}
+	assignDeep(res.Data, sub.Data)
+	for _, e := range sub.Errors {
+		if !strings.HasPrefix(e.Path, "downloads/") {
+			res.Errors = append(res.Errors, e)
+		}
+	}
+	for k, ext := range sub.ExternalSection {
+		if res.ExternalSection == nil {
+			res.ExternalSection = make(map[string]ExternalResult)
+		}
+		res.ExternalSection[k] = ext
+	}
+	return res, nil
+}
@@ -0,0 +1,32 @@
+package rpa
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-rod/rod/lib/input"
+)
+
+func Test_parseKeys(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []input.Key
+	}{
+		{"Enter", []input.Key{input.Enter}},
+		{"a", []input.Key{'a'}},
+		{"Control+a", []input.Key{input.ControlLeft, 'a'}},
+		{"Shift++", []input.Key{input.ShiftLeft, '+'}},
+		{"+", []input.Key{'+'}},
+	}
+	for _, tt := range tests {
+		got, err := parseKeys(tt.value)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeys(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"", "Nope", "Control+", "ab"} {
+		if _, err := parseKeys(value); err == nil {
+			t.Errorf("parseKeys(%q): expected an error", value)
+		}
+	}
+}
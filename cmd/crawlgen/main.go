@@ -0,0 +1,53 @@
+// Command crawlgen generates a crawler config skeleton from a Go struct annotated with `crawl` tags.
+//
+//	crawlgen -type Page [-o page.json] model.go [more.go ...]
+//
+// See rpa.GenerateConfig for the tag options.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	rpa "github.com/rpdg/rod-helper"
+)
+
+func main() {
+	typeName := flag.String("type", "", "name of the struct type")
+	output := flag.String("o", "", "output file, stdout if empty")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "usage: crawlgen -type Name [-o config.json] file.go ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *typeName == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := rpa.GenerateConfigFromFiles(*typeName, flag.Args()...)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "crawlgen:", err)
+		os.Exit(1)
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "crawlgen:", err)
+		os.Exit(1)
+	}
+	b = append(b, '\n')
+
+	if *output == "" {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = os.WriteFile(*output, b, 0644)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "crawlgen:", err)
+		os.Exit(1)
+	}
+}
@@ -1,14 +1,20 @@
 package rpa
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/input"
@@ -28,12 +34,16 @@ type PageLoad struct {
 	Wait     WaitSign `json:"wait"`
 	Selector string   `json:"selector,omitempty"`
 	Sleep    int64    `json:"sleep,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type ConfigNode struct {
 	Selector string `json:"selector"`
-	Label    string `json:"label"`
+	Label    string `json:"label,omitempty"`
 	ID       string `json:"id"`
+	// DomRender is a JavaScript function body taking 1 fixed parameter: dom, it returns the elements to crawl instead of the selected ones
+	DomRender string `json:"domRender,omitempty"`
 }
 
 type DownloadTypeString string
@@ -53,16 +63,23 @@ type DownloadConfig struct {
 	LinkRender   string             `json:"linkRender,omitempty"`
 	InsertTo     string             `json:"insertTo,omitempty"`
 	DownloadType DownloadTypeString `json:"downloadType"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type DictData map[string]interface{}
 
 type CrawlerConfig struct {
 	PageLoad        PageLoad         `json:"pageLoad,omitempty"`
-	DataSection     []DictData       `json:"dataSection"`
-	SwitchSection   DictData         `json:"switchSection,omitempty"`
+	Login           *LoginProfile    `json:"login,omitempty"`
+	Actions         []Action         `json:"actions,omitempty"`
+	DataSection     DataNodes        `json:"dataSection"`
+	SwitchSection   *SwitchSection   `json:"switchSection,omitempty"`
 	DownloadRoot    string           `json:"downloadRoot,omitempty"`
 	DownloadSection []DownloadConfig `json:"downloadSection,omitempty"`
+	Pagination      *Pagination      `json:"pagination,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type DownloadFileInfo struct {
@@ -78,9 +95,45 @@ type DownloadResult struct {
 }
 
 type ExternalResult struct {
-	Config  string `json:"config"`
-	Connect string `json:"connect"`
-	ID      string `json:"id"`
+	Config  ExternalConfig `json:"config"`
+	Connect string         `json:"connect"`
+	ID      string         `json:"id"`
+}
+
+// ExternalConfig is the config of an external link, either the path of a config file
+// relative to the referencing config, or an embedded config object
+type ExternalConfig struct {
+	Path   string
+	Inline *CrawlerConfig
+}
+
+func (e ExternalConfig) IsZero() bool {
+	return e.Path == "" && e.Inline == nil
+}
+
+func (e ExternalConfig) MarshalJSON() ([]byte, error) {
+	if e.Inline != nil {
+		return json.Marshal(e.Inline)
+	}
+	return json.Marshal(e.Path)
+}
+
+func (e *ExternalConfig) UnmarshalJSON(b []byte) error {
+	*e = ExternalConfig{}
+	trimmed := strings.TrimSpace(string(b))
+	switch {
+	case trimmed == "null":
+		return nil
+	case strings.HasPrefix(trimmed, "{"):
+		var cfg CrawlerConfig
+		if err := json.Unmarshal(b, &cfg); err != nil {
+			return fmt.Errorf("invalid embedded external config: %w", err)
+		}
+		e.Inline = &cfg
+		return nil
+	default:
+		return json.Unmarshal(b, &e.Path)
+	}
 }
 
 type Result struct {
@@ -88,26 +141,218 @@ type Result struct {
 	DownloadRoot    string                    `json:"downloadRoot"`
 	Downloads       map[string]DownloadResult `json:"downloads"`
 	ExternalSection map[string]ExternalResult `json:"externalSection"`
+	Errors          []CrawlError              `json:"errors,omitempty"`
+
+	// matchedCase is the index of the case of the switchSection matched by extract, nil if none matched
+	matchedCase *int
+}
+
+// Stages of a crawl where a CrawlError occurs
+const (
+	StageValueRender  = "valueRender"
+	StageFilterRender = "filterRender"
+	StageDataRender   = "dataRender"
+	StageNameRender   = "nameRender"
+	StageLinkRender   = "linkRender"
+	StageSwitchRender = "switchRender"
+	StageDownload     = "download"
+	StageExternal     = "external"
+	StageFrame        = "frame"
+	StageAction       = "action"
+	StageFill         = "fill"
+)
+
+// CrawlError is a failure of a single node of the crawl, the crawl goes on unless CrawlOptions.Strict is set
+type CrawlError struct {
+	// Path is the slash separated path of the failed node in the result, such as "list/0/title" or "downloads/attachments/files/1",
+	// or the path of the failed action in the config, such as "actions/2"
+	Path string `json:"path"`
+	// Stage is where the error occurred, one of the Stage constants
+	Stage string `json:"stage"`
+	// Selector is the selector of the failed node, if any
+	Selector string `json:"selector,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (e CrawlError) Error() string {
+	if e.Selector != "" {
+		return fmt.Sprintf("%s at %s (%s): %s", e.Stage, e.Path, e.Selector, e.Message)
+	}
+	return fmt.Sprintf("%s at %s: %s", e.Stage, e.Path, e.Message)
+}
+
+// firstError returns the first of errs, nil if there is none
+func firstError(errs []CrawlError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs[0]
+}
+
+// CrawlCanceledError is returned when the context of a crawl is done before the crawl finishes,
+// the partial result collected so far is returned along with it.
+type CrawlCanceledError struct {
+	Cause error
+}
+
+func (e *CrawlCanceledError) Error() string {
+	return fmt.Sprintf("crawl canceled: %s", e.Cause)
+}
+
+func (e *CrawlCanceledError) Unwrap() error {
+	return e.Cause
+}
+
+// canceledOr returns a CrawlCanceledError if ctx is done, otherwise err
+func canceledOr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return &CrawlCanceledError{Cause: ctx.Err()}
+	}
+	return err
+}
+
+// Engine is the way a page is extracted by the config
+type Engine string
+
+const (
+	// EngineJS runs the embedded crawler.js in the page, it's the default
+	EngineJS Engine = "js"
+	// EngineNative walks the config from Go with the rod element API, for the pages breaking crawler.js,
+	// such as the ones overriding Function, Array.from or querySelectorAll, or having a CSP that blocks new Function.
+	// The result is the same as EngineJS, but it's slower as each node takes a few calls to the browser.
+	EngineNative Engine = "native"
+)
+
+// CrawlOptions holds the settings of a crawl that are not part of the CrawlerConfig.
+// The zero value of each field means the default behavior.
+type CrawlOptions struct {
+	// AutoDownload downloads the files of the downloadSection
+	AutoDownload bool
+
+	// CloseTab closes the tab when the crawl ends
+	CloseTab bool
+
+	// StableDuration is the duration the page must stay unchanged before crawling, defaults to 1 second
+	StableDuration time.Duration
+
+	// WaitTimeout is the max time to wait for the pageLoad selector to show or hide, defaults to 30 seconds
+	WaitTimeout time.Duration
+
+	// DownloadRoot overrides the downloadRoot of the config
+	DownloadRoot string
+
+	// DownloadTempDir is where the browser stages the downloading files, defaults to os.TempDir()/rod/downloads
+	DownloadTempDir string
+
+	// DownloadTimeout is the max time to wait for a single file download, no limit if zero
+	DownloadTimeout time.Duration
+
+	// ExternalConcurrency is the max number of tabs crawling the external links at the same time, defaults to 1
+	ExternalConcurrency int
+
+	// HostConcurrency is the max number of tabs crawling the external links of the same host at the same time,
+	// no limit other than ExternalConcurrency if zero
+	HostConcurrency int
+
+	// MaxExternalDepth is the max depth of nested external crawls, the links of the crawled page are depth 1,
+	// no limit if zero
+	MaxExternalDepth int
+
+	// ExternalBudget is the max number of external pages to crawl in total, no limit if zero
+	ExternalBudget int
+
+	// Strict fails the crawl on the first CrawlError, the partial result is returned along with it.
+	// Otherwise the errors are collected in Result.Errors.
+	Strict bool
+
+	// Engine is the extraction engine, EngineJS if empty
+	Engine Engine
+
+	// Incognito crawls the url of CrawlUrl in a new incognito context of the browser, set up by the options.
+	// The context is disposed of when the crawl ends, along with its tabs, so its cookies and storage don't leak
+	// to the other crawls. The page of the crawl is closed whatever CloseTab is, and no page is returned:
+	// use a crawler of Crawler.Incognito to keep the page. It's ignored by CrawlPage.
+	Incognito *IncognitoOptions
+
+	// ConfigBase is the path or url that the relative external config paths of a config object are resolved against,
+	// the working directory is used if empty. Configs loaded from a path are resolved against their own path.
+	ConfigBase string
+}
+
+func (o CrawlOptions) withDefaults() CrawlOptions {
+	if o.StableDuration <= 0 {
+		o.StableDuration = time.Second
+	}
+	if o.WaitTimeout <= 0 {
+		o.WaitTimeout = 30 * time.Second
+	}
+	if o.DownloadTempDir == "" {
+		o.DownloadTempDir = defaultDownloadTempDir()
+	}
+	if o.ExternalConcurrency <= 0 {
+		o.ExternalConcurrency = 1
+	}
+	return o
+}
+
+func defaultDownloadTempDir() string {
+	return filepath.Join(os.TempDir(), "rod", "downloads")
 }
 
 type Crawler struct {
 	Browser    *rod.Browser
 	CfgFetcher func(path string) (*CrawlerConfig, error)
+
+	// Login is the login of the configs having no login block, optional
+	Login *LoginProfile
+	// Secrets supplies the values of the login fields having a secret, optional
+	Secrets SecretProvider
+
+	loginMu    sync.Mutex
+	loginCount int
+
+	stateMu sync.Mutex
+	// sessionStorage is the imported sessionStorage by origin, set in the tabs opened by CrawlUrl
+	sessionStorage map[string]map[string]string
+
+	// downloadTempDir is the default CrawlOptions.DownloadTempDir, the dir of an incognito context
+	downloadTempDir string
+	ownsTempDir     bool
 }
 
+// Close closes the browser, or disposes of the context of a crawler returned by Incognito
 func (c *Crawler) Close() {
-	c.Browser.MustClose()
+	utils.E(c.dispose())
 }
 
 func (c *Crawler) CrawlUrl(url string, cfgOrFile interface{}, autoDownload bool, closeTab bool) (*Result, *rod.Page, error) {
+	return c.CrawlUrlContext(context.Background(), url, cfgOrFile, autoDownload, closeTab)
+}
+
+// CrawlUrlContext is like CrawlUrl but stops when ctx is done.
+// A canceled crawl returns the partial result with a *CrawlCanceledError, and the tab is always closed.
+func (c *Crawler) CrawlUrlContext(ctx context.Context, url string, cfgOrFile interface{}, autoDownload bool, closeTab bool) (*Result, *rod.Page, error) {
+	return c.CrawlUrlWithOptions(ctx, url, cfgOrFile, CrawlOptions{AutoDownload: autoDownload, CloseTab: closeTab})
+}
+
+// CrawlUrlWithOptions opens the url in a new tab and crawls it with opts
+func (c *Crawler) CrawlUrlWithOptions(ctx context.Context, url string, cfgOrFile interface{}, opts CrawlOptions) (*Result, *rod.Page, error) {
+	if opts.Incognito != nil {
+		return c.crawlIncognito(ctx, url, cfgOrFile, opts)
+	}
+
 	var err error
 
-	page, err := c.Browser.Page(proto.TargetCreateTarget{URL: url})
+	page, err := c.openPage(c.Browser, url)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, canceledOr(ctx, err)
 	}
 
-	res, err := c.CrawlPage(page, cfgOrFile, autoDownload, closeTab)
+	res, err := c.CrawlPageWithOptions(ctx, page, cfgOrFile, opts)
+	var canceled *CrawlCanceledError
+	if errors.As(err, &canceled) && !opts.CloseTab {
+		_ = page.Close()
+	}
 	return res, page, err
 }
 
@@ -116,58 +361,140 @@ func (c *Crawler) CrawlUrl(url string, cfgOrFile interface{}, autoDownload bool,
 //}
 
 func (c *Crawler) CrawlPage(page *rod.Page, cfgOrFile interface{}, autoDownload bool, closeTab bool) (*Result, error) {
-	var cfg *CrawlerConfig
-	var err error
+	return c.CrawlPageContext(context.Background(), page, cfgOrFile, autoDownload, closeTab)
+}
+
+// CrawlPageContext is like CrawlPage but stops when ctx is done.
+// The ctx is carried into the page load waiting, the crawler script, the downloads and the external crawls.
+// A canceled crawl returns the partial result with a *CrawlCanceledError.
+func (c *Crawler) CrawlPageContext(ctx context.Context, page *rod.Page, cfgOrFile interface{}, autoDownload bool, closeTab bool) (*Result, error) {
+	return c.CrawlPageWithOptions(ctx, page, cfgOrFile, CrawlOptions{AutoDownload: autoDownload, CloseTab: closeTab})
+}
+
+// CrawlPageWithOptions crawls the page with opts
+func (c *Crawler) CrawlPageWithOptions(ctx context.Context, page *rod.Page, cfgOrFile interface{}, opts CrawlOptions) (*Result, error) {
+	if opts.DownloadTempDir == "" {
+		opts.DownloadTempDir = c.downloadTempDir
+	}
+	opts = opts.withDefaults()
+	cfg, cfgFilePath, err := c.resolveCfg(ctx, cfgOrFile, opts.ConfigBase)
+	if err != nil {
+		if opts.CloseTab {
+			_ = page.Close()
+		}
+		return nil, canceledOr(ctx, err)
+	}
+
+	sess := newCrawlSession(opts)
+	if info, err := page.Info(); err == nil {
+		sess.visit(info.URL, cfgFilePath)
+	}
+
+	result, err := c.crawlPage(ctx, page, cfg, opts)
+	if err != nil {
+		return result, err
+	}
+
+	err = c.crawlExternals(ctx, result, cfgFilePath, 1, opts, sess)
+	return result, err
+}
 
-	cfgFilePath := ""
+// resolveCfg returns the config of cfgOrFile and its source,
+// the source of a config object is base.
+func (c *Crawler) resolveCfg(ctx context.Context, cfgOrFile interface{}, base string) (*CrawlerConfig, string, error) {
 	switch val := cfgOrFile.(type) {
 	case string:
-		cfg, err = c.fetchCfg(val)
+		return c.fetchCfg(ctx, val)
 	case CrawlerConfig:
-		cfg = &val
+		return &val, base, nil
 	case *CrawlerConfig:
-		cfg = val
+		return val, base, nil
 	default:
-		err = errors.New("unknown config data")
+		return nil, "", errors.New("unknown config data")
 	}
+}
 
-	if err != nil {
-		return nil, err
+// crawlPage crawls everything of the page except the external links
+func (c *Crawler) crawlPage(ctx context.Context, page *rod.Page, cfg *CrawlerConfig, opts CrawlOptions) (*Result, error) {
+	var err error
+
+	if opts.CloseTab {
+		defer func() { _ = page.Close() }()
+	}
+
+	p := page.Context(ctx)
+
+	if lp := c.loginProfile(cfg); lp != nil {
+		if err = c.ensureLogin(ctx, p, lp, opts); err != nil {
+			return nil, canceledOr(ctx, err)
+		}
 	}
 
 	wait := cfg.PageLoad.Wait
 	selector := cfg.PageLoad.Selector
 	delay := cfg.PageLoad.Sleep
-	err = WaitPage(page, delay, selector, wait)
+	err = waitPage(ctx, p, delay, selector, wait, opts.StableDuration, opts.WaitTimeout)
 	if err != nil {
-		return nil, err
+		return nil, canceledOr(ctx, err)
 	}
 
-	jsCode := fmt.Sprintf(`
-	(cfg)=>{
-		%s;
-		debugger;
-		return run(cfg);
-	}`, crawlerJs)
+	actionErrs, err := runActions(ctx, p, cfg.Actions, "actions", opts)
+	if err != nil {
+		return nil, canceledOr(ctx, err)
+	}
+	if opts.Strict && len(actionErrs) > 0 {
+		return &Result{Data: DictData{}, Errors: actionErrs}, firstError(actionErrs)
+	}
 
-	resultJson, err := page.Eval(jsCode, cfg)
+	var res *Result
+	if hasCaseActions(cfg) {
+		res, err = extractSwitchActions(ctx, p, cfg, opts)
+	} else {
+		res, err = extract(p, cfg, opts.Engine)
+	}
 	if err != nil {
-		return nil, err
+		return nil, canceledOr(ctx, err)
+	}
+	result := *res
+	result.Errors = append(actionErrs, result.Errors...)
+	if opts.Strict && len(result.Errors) > 0 {
+		return &result, firstError(result.Errors)
 	}
 
-	var result Result
-	err = resultJson.Value.Unmarshal(&result)
+	err = scrollSections(ctx, p, cfg.DataSection, &result, opts)
 	if err != nil {
-		return nil, err
+		return &result, canceledOr(ctx, err)
+	}
+	if opts.Strict && len(result.Errors) > 0 {
+		return &result, firstError(result.Errors)
+	}
+
+	if opts.DownloadRoot != "" {
+		result.DownloadRoot = opts.DownloadRoot
 	}
 
-	if autoDownload && cfg.DownloadSection != nil && result.Downloads != nil {
+	if opts.AutoDownload && cfg.DownloadSection != nil && result.Downloads != nil {
 		dlsMap := result.Downloads
 		downloadRoot := result.DownloadRoot
 		for _, dlCfgItem := range cfg.DownloadSection {
+			if ctx.Err() != nil {
+				return &result, canceledOr(ctx, nil)
+			}
 			key := dlCfgItem.ID
 			if dlDataItem, ok := dlsMap[key]; ok {
-				rnm, _ := c.download(page, dlCfgItem, &dlDataItem, downloadRoot)
+				rnm, dlErrs, err := c.download(ctx, p, dlCfgItem, &dlDataItem, downloadRoot, opts)
+				result.Errors = append(result.Errors, dlErrs...)
+				if err != nil && ctx.Err() == nil {
+					result.Errors = append(result.Errors, CrawlError{
+						Path:     "downloads/" + key,
+						Stage:    StageDownload,
+						Selector: dlCfgItem.Selector,
+						Message:  err.Error(),
+					})
+				}
+				if opts.Strict && len(result.Errors) > 0 {
+					return &result, firstError(result.Errors)
+				}
 				if len(rnm) > 0 && len(dlCfgItem.InsertTo) > 0 {
 					pathArr := strings.Split(dlCfgItem.InsertTo, ".")
 					var targetSec map[string]interface{}
@@ -187,64 +514,86 @@ func (c *Crawler) CrawlPage(page *rod.Page, cfgOrFile interface{}, autoDownload
 		}
 	}
 
-	if result.ExternalSection != nil {
-		for _, extItem := range result.ExternalSection {
-			if extItem.Config != "" {
-				extCfg, _ := joinPath(cfgFilePath, extItem.Config)
-				cc := extItem.Connect
-				//parts := strings.Split(cc, "/")
-				//secName := parts[0]
-				var itemName string
+	err = c.paginate(ctx, p, cfg, &result, opts)
+	if err != nil {
+		return &result, canceledOr(ctx, err)
+	}
+	if opts.Strict && len(result.Errors) > 0 {
+		return &result, firstError(result.Errors)
+	}
 
-				var resNode interface{}
-				resNode = result.Data
+	return &result, nil
+}
 
-				if cc != "" {
-					resNode, itemName, err = GetDictAndLastSegmentByPath(resNode.(DictData), cc)
-					if err != nil {
-						return nil, err
-					}
-				}
+// extract crawls the data and the download links of the page with the engine,
+// the closed shadow roots are read once for the whole extraction
+func extract(page *rod.Page, cfg *CrawlerConfig, engine Engine) (*Result, error) {
+	roots, err := shadowRootsFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer roots.release()
 
-				if resNode != nil {
-					switch resNode.(type) {
-					case []interface{}:
-						for _, resExtNode := range resNode.([]interface{}) {
-							if extNode, oke := resExtNode.(map[string]interface{}); oke {
-								c.processExtUrl(extCfg, extNode, itemName, autoDownload)
-							}
-						}
-					case map[string]interface{}:
-						if extNode, oke := resNode.(map[string]interface{}); oke {
-							c.processExtUrl(extCfg, extNode, itemName, autoDownload)
-						}
-					default:
-						return nil, fmt.Errorf("unexpected externalSection type %T", resNode)
-					}
-				}
-			}
+	switch engine {
+	case "", EngineJS:
+		res, err := evalCrawler(page, cfg, roots)
+		if err != nil {
+			return nil, err
 		}
+		crawlCrossFrames(page, cfg, res, engine, roots)
+		return res, nil
+	case EngineNative:
+		return crawlNative(page, cfg, roots)
+	default:
+		return nil, fmt.Errorf("unknown engine %q", engine)
 	}
-	if closeTab {
-		_ = page.Close()
-	}
-	return &result, nil
 }
 
-func (c *Crawler) processExtUrl(extCfg string, extNode DictData, itemName string, autoDownload bool) {
-	extUrl := extNode[itemName].(string)
-	if extUrl != "" {
-		extData, _, err2 := c.CrawlUrl(extUrl, extCfg, autoDownload, true)
-		if err2 != nil {
-			extNode[itemName] = fmt.Sprintf("an error occurred when crawling the external url: %s", err2)
-		} else {
-			extNode[itemName] = extData.Data
-		}
+// evalCrawler runs the embedded crawler.js with cfg in the page, the closed shadow roots are read from roots
+func evalCrawler(page *rod.Page, cfg *CrawlerConfig, roots *shadowRoots) (*Result, error) {
+	rootsArg, err := roots.arg(page)
+	if err != nil {
+		return nil, err
 	}
+
+	jsCode := fmt.Sprintf(`
+	(cfg, roots)=>{
+		%s;
+		debugger;
+		return run(cfg, roots);
+	}`, crawlerJs)
+
+	resultJson, err := page.Eval(jsCode, cfg, rootsArg)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Result
+		MatchedCase *int `json:"matchedCase"`
+	}
+	err = resultJson.Value.Unmarshal(&result)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCase != nil && *result.MatchedCase >= 0 {
+		result.matchedCase = result.MatchedCase
+	}
+	return &result.Result, nil
 }
 
-func (c *Crawler) download(page *rod.Page, dlCfg DownloadConfig, dlData *DownloadResult, downloadRoot string) (renamed map[int]string, err error) {
+// download saves the files of dlData, the failed files are returned as errs along with their error set
+func (c *Crawler) download(ctx context.Context, page *rod.Page, dlCfg DownloadConfig, dlData *DownloadResult, downloadRoot string, opts CrawlOptions) (renamed map[int]string, errs []CrawlError, err error) {
 	renamed = make(map[int]string)
+	fail := func(i int, err error) {
+		dlData.Files[i].Error = err.Error()
+		errs = append(errs, CrawlError{
+			Path:     fmt.Sprintf("downloads/%s/files/%d", dlCfg.ID, i),
+			Stage:    StageDownload,
+			Selector: dlCfg.Selector,
+			Message:  err.Error(),
+		})
+	}
 	selector := dlCfg.Selector
 	downType := dlCfg.DownloadType
 
@@ -260,92 +609,185 @@ func (c *Crawler) download(page *rod.Page, dlCfg DownloadConfig, dlData *Downloa
 	//	return err
 	//}
 
-	browser := page.Browser()
-	elems, err := page.Elements(selector)
+	elems, err := queryElems(page, selector)
 	if err != nil {
 		return
 	}
 
 	for i, elem := range elems {
+		if ctx.Err() != nil {
+			return renamed, errs, ctx.Err()
+		}
+		if i >= len(dlData.Files) {
+			break
+		}
 		if dlData.Files[i].Error != "" {
 			continue
 		}
 		fileFullPathName := filepath.Join(saveDir, dlData.Files[i].Name)
+		browser, cancel := downloadBrowser(ctx, page, opts.DownloadTimeout)
 		if downType == PrintToPDF {
-			br := page.Browser()
-			linkPage := br.MustPage(dlData.Files[i].Url)
-			linkPage.MustWaitStable()
-			_ = linkPage.MustPDF(fileFullPathName)
-			linkPage.MustClose()
+			err = printToPDF(browser, dlData.Files[i].Url, fileFullPathName)
+			cancel()
+			if err != nil {
+				fail(i, err)
+			}
 		} else {
 			if downType == DownloadUrl {
 				_ = page.Keyboard.Press(input.AltLeft)
 			}
-			elem.MustClick()
 
+			waitDownload := waitDownloadRelax(browser, opts.DownloadTempDir)
 			var fileData []byte
+			var fileName string
+			err = elem.Click(proto.InputMouseButtonLeft, 1)
+			if err == nil {
+				fileData, fileName, err = waitDownload()
+			}
+			cancel()
+			if downType == DownloadUrl {
+				_ = page.Keyboard.Release(input.AltLeft)
+			}
+			if err != nil {
+				fail(i, err)
+				continue
+			}
+
 			if dlCfg.NameRender == "auto" {
-				waitDownload := MustWaitDownloadRelax(browser)
-				var fileName string
-				fileData, fileName = waitDownload()
 				if len(fileName) > 0 && fileName != dlData.Files[i].Name {
 					renamed[i] = fileName
 					dlData.Files[i].Name = fileName
 					fileFullPathName = filepath.Join(saveDir, fileName)
 				}
-			} else {
-				waitDownload := browser.MustWaitDownload()
-				fileData = waitDownload()
 			}
 
 			err = utils.OutputFile(fileFullPathName, fileData)
 			if err != nil {
-				dlData.Files[i].Error = err.Error()
+				fail(i, err)
 				continue
 			}
-			if downType == DownloadUrl {
-				_ = page.Keyboard.Release(input.AltLeft)
-			}
 		}
 	}
 
-	return
+	return renamed, errs, nil
+}
+
+// downloadBrowser returns the browser of page bound to ctx, limited by timeout if it's positive
+func downloadBrowser(ctx context.Context, page *rod.Page, timeout time.Duration) (*rod.Browser, context.CancelFunc) {
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return page.Browser().Context(ctx), cancel
+	}
+	return page.Browser().Context(ctx), func() {}
+}
+
+// printToPDF opens the link in a new tab and saves it as a pdf file
+func printToPDF(browser *rod.Browser, link string, file string) error {
+	linkPage, err := browser.Page(proto.TargetCreateTarget{URL: link})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = linkPage.Context(context.Background()).Close() }()
+
+	err = linkPage.WaitStable(time.Second)
+	if err != nil {
+		return err
+	}
+	r, err := linkPage.PDF(&proto.PagePrintToPDF{})
+	if err != nil {
+		return err
+	}
+	bin, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return utils.OutputFile(file, bin)
 }
 
 func MustWaitDownloadRelax(b *rod.Browser) func() ([]byte, string) {
-	tmpDir := filepath.Join(os.TempDir(), "rod", "downloads")
+	wait := WaitDownloadRelax(b)
+
+	return func() ([]byte, string) {
+		data, n, _ := wait()
+		return data, n
+	}
+}
+
+// WaitDownloadRelax returns a helper to get the next download file and its suggested name,
+// the helper returns an error if the context of b is done before the download completes.
+func WaitDownloadRelax(b *rod.Browser) func() ([]byte, string, error) {
+	return waitDownloadRelax(b, defaultDownloadTempDir())
+}
+
+func waitDownloadRelax(b *rod.Browser, tmpDir string) func() ([]byte, string, error) {
 	wait := b.WaitDownload(tmpDir)
 
-	return func() (data []byte, n string) {
+	return func() ([]byte, string, error) {
 		info := wait()
+		if err := b.GetContext().Err(); err != nil {
+			return nil, "", err
+		}
+		if info == nil {
+			return nil, "", errors.New("download not started")
+		}
 		path := filepath.Join(tmpDir, info.GUID)
 		defer func() { _ = os.Remove(path) }()
-		rod.Try(func() {
-			data, _ = os.ReadFile(path)
-		})
-		return data, info.SuggestedFilename
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, info.SuggestedFilename, nil
 	}
 }
 
-func (c *Crawler) fetchCfg(cfgPath string) (*CrawlerConfig, error) {
+// fetchCfg loads the config of cfgPath, it also returns the source of the config,
+// which is the base to resolve the relative paths of its external configs.
+// A config url is fetched with ctx, a CfgFetcher isn't stopped by it.
+func (c *Crawler) fetchCfg(ctx context.Context, cfgPath string) (*CrawlerConfig, string, error) {
 	if c.CfgFetcher != nil {
 		cfg, err := c.CfgFetcher(cfgPath)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		} else {
-			_, err2 := json.Marshal(cfg)
-			return cfg, err2
+			return cfg, cfgPath, checkCfg(cfg)
 		}
 	} else {
-		return innerFetcher(cfgPath)
+		source := cfgPath
+		if !isUrl(source) {
+			if abs, err := filepath.Abs(source); err == nil {
+				source = abs
+			}
+		}
+		cfg, err := innerFetcher(ctx, source)
+		if err != nil {
+			return nil, "", err
+		}
+		return cfg, source, checkCfg(cfg)
 	}
 }
 
-func innerFetcher(cfgFilePath string) (*CrawlerConfig, error) {
-	cfgJsonStr, err := os.ReadFile(cfgFilePath)
+// checkCfg validates a loaded or embedded config
+func checkCfg(cfg *CrawlerConfig) error {
+	if cfg == nil {
+		return errors.New("nil config")
+	}
+	_, err := json.Marshal(cfg)
 	if err != nil {
-		fmt.Println("Error reading config file:", err)
-		return nil, err
+		return err
+	}
+	return ValidateConfig(cfg)
+}
+
+func innerFetcher(ctx context.Context, cfgFilePath string) (*CrawlerConfig, error) {
+	var cfgJsonStr []byte
+	var err error
+	if isUrl(cfgFilePath) {
+		cfgJsonStr, err = httpGet(ctx, cfgFilePath)
+	} else {
+		cfgJsonStr, err = os.ReadFile(cfgFilePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 	var cfg CrawlerConfig
 	err = json.Unmarshal(cfgJsonStr, &cfg)
@@ -355,13 +797,54 @@ func innerFetcher(cfgFilePath string) (*CrawlerConfig, error) {
 	return &cfg, nil
 }
 
+// cfgClient fetches the config urls, a config server that doesn't answer fails the crawl instead of blocking it
+var cfgClient = &http.Client{Timeout: 30 * time.Second}
+
+func httpGet(ctx context.Context, link string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfgClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", link, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+var urlSchemePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://`)
+
+// isUrl reports whether p has a scheme like http:// or https://
+func isUrl(p string) bool {
+	return urlSchemePattern.MatchString(p)
+}
+
+// joinCfgPath resolves the external config path refPath against basePath, the source of the config referencing it.
+// Paths of a CfgFetcher are joined as they are, without turning them into absolute file paths.
+func (c *Crawler) joinCfgPath(basePath, refPath string) (string, error) {
+	if c.CfgFetcher != nil && !isUrl(basePath) && !isUrl(refPath) {
+		if basePath == "" || filepath.IsAbs(refPath) || strings.HasPrefix(refPath, "/") {
+			return refPath, nil
+		}
+		return filepath.Join(filepath.Dir(basePath), refPath), nil
+	}
+	return joinPath(basePath, refPath)
+}
+
 func joinPath(basePath, refPath string) (string, error) {
-	if strings.HasPrefix(basePath, "http://") || strings.HasPrefix(basePath, "https://") {
+	if isUrl(refPath) {
+		return refPath, nil
+	}
+	if isUrl(basePath) {
 		base, err := url.Parse(basePath)
 		if err != nil {
 			return "", err
 		}
-		ref, err := url.Parse(refPath)
+		ref, err := url.Parse(filepath.ToSlash(refPath))
 		if err != nil {
 			return "", err
 		}
@@ -1,32 +1,72 @@
 package rpa
 
 import (
-	"encoding/json"
-	"os"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
-func Test_CrawlUrl(t *testing.T) {
-	t.Run("crawl bing.com and download PDF files", func(t *testing.T) {
-		testUrl := "https://www.learningcontainer.com/sample-zip-files/"
-		r := Crawler{}
-		err := r.AttachEmbedBrowser()
-		if err != nil {
-			t.Errorf("connect browser failed: %v", err)
-			return
+func Test_joinCfgPath(t *testing.T) {
+	r := Crawler{}
+	cases := []struct {
+		base, ref, expected string
+	}{
+		{"https://cfg.test/site/master.json", "detail.json", "https://cfg.test/site/detail.json"},
+		{"https://cfg.test/site/master.json", "../common/detail.json", "https://cfg.test/common/detail.json"},
+		{"https://cfg.test/site/master.json", "https://other.test/detail.json", "https://other.test/detail.json"},
+	}
+	for _, c := range cases {
+		got, err := r.joinCfgPath(c.base, c.ref)
+		if err != nil || got != c.expected {
+			t.Errorf("joinCfgPath(%q, %q) = %q, %v, expected %q", c.base, c.ref, got, err, c.expected)
 		}
-		val, _, err := r.CrawlUrl(testUrl, "./sample/sample_zip.json", true, true)
-		if err != nil {
-			t.Errorf("crawler failed: %v", err)
+	}
+
+	base, _ := filepath.Abs(filepath.Join("cfg", "site", "master.json"))
+	expected, _ := filepath.Abs(filepath.Join("cfg", "common", "detail.json"))
+	if got, _ := r.joinCfgPath(base, "../common/detail.json"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	r.CfgFetcher = func(path string) (*CrawlerConfig, error) { return nil, nil }
+	expected = filepath.Join("site", "detail.json")
+	if got, _ := r.joinCfgPath(filepath.Join("site", "master.json"), "detail.json"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func Test_httpGet(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.json" {
+			http.NotFound(w, r)
 			return
 		}
-		if _, ok := val.Data["linkList"]; ok {
-			b, _ := json.MarshalIndent(val, "", "\t")
-			err2 := os.WriteFile("./zip_result.json", b, 0644)
-			if err2 != nil {
-				t.Errorf("%v", err2)
-			}
+		select {
+		case <-block:
+		case <-r.Context().Done():
 		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	if _, err := httpGet(context.Background(), srv.URL+"/missing.json"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
 
-	})
+	// a config server that doesn't answer is given up with the crawl
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := httpGet(ctx, srv.URL+"/slow.json"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline of ctx, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("httpGet blocked for %v after ctx was done", d)
+	}
 }
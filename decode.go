@@ -0,0 +1,401 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DecodeError is returned by Result.Decode when a value of the result can't be put into the Go value
+type DecodeError struct {
+	// Path is the slash separated path of the value in the result data, such as "list/3/price"
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s: %s", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// crawlTag is the parsed `crawl` tag of a struct field.
+//
+// The first element is the id of the item or section, the name of the field is used if it's empty.
+// The other elements are options, either a flag such as "number" or a key=value pair such as "date=2006-01-02".
+type crawlTag struct {
+	ID   string
+	Opts map[string]string
+}
+
+func (t crawlTag) has(opt string) bool {
+	_, ok := t.Opts[opt]
+	return ok
+}
+
+func parseCrawlTag(tag string) crawlTag {
+	parts := strings.Split(tag, ",")
+	t := crawlTag{Opts: make(map[string]string)}
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		k, v, isPair := strings.Cut(p, "=")
+		if i == 0 && !isPair {
+			t.ID = p
+			continue
+		}
+		if k != "" {
+			t.Opts[k] = v
+		}
+	}
+	return t
+}
+
+// fieldTag returns the crawl tag of a struct field, the json tag name is used as the id if the crawl tag has none
+func fieldTag(f reflect.StructField) (crawlTag, bool) {
+	tag, tagged := f.Tag.Lookup("crawl")
+	t := parseCrawlTag(tag)
+	if t.ID == "" {
+		if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "-" {
+			t.ID = name
+		}
+	}
+	return t, tagged
+}
+
+// Decode puts the data of the result into v, a pointer to a struct.
+//
+// The fields are matched with the section and item ids by the `crawl` tag, the `json` tag or the field name,
+// a list section goes to a slice of structs, a form section or a crawled external link goes to a struct.
+// A field of slice type takes the files of an insertTo node directly.
+//
+// A string is converted to a number, a time.Time or a bool only by the tag options,
+// render=number of GenerateConfig implies the number option:
+//
+//	Price   float64   `crawl:"price,number"`          // "¥1,234.50" => 1234.5
+//	Date    time.Time `crawl:"date,date=2006-01-02"`  // layouts with commas are not supported
+//	Checked bool      `crawl:"checked,bool=Yes"`      // true if the value is "Yes", strconv.ParseBool if there is no value
+//
+// A field tagged "-" is skipped. The ids missing in the data leave the fields unchanged.
+func (r *Result) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("decode requires a non-nil pointer, got %T", v)
+	}
+	return decodeValue(map[string]interface{}(r.Data), rv.Elem(), crawlTag{}, "")
+}
+
+// CrawlInto crawls the url like CrawlUrlWithOptions and decodes the data into a T, see Result.Decode.
+// The tab is always closed. A partial result of a failed crawl is decoded as well.
+func CrawlInto[T any](ctx context.Context, c *Crawler, url string, cfgOrFile interface{}, opts CrawlOptions) (T, *Result, error) {
+	var v T
+	opts.CloseTab = true
+	res, _, err := c.CrawlUrlWithOptions(ctx, url, cfgOrFile, opts)
+	if res != nil {
+		decodeErr := res.Decode(&v)
+		if err == nil {
+			err = decodeErr
+		}
+	}
+	return v, res, err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func decodeValue(data interface{}, rv reflect.Value, tag crawlTag, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		return &DecodeError{Path: path, Err: fmt.Errorf(format, args...)}
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	switch {
+	case rv.Kind() == reflect.Pointer:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(data, rv.Elem(), tag, path)
+	case rv.Kind() == reflect.Interface && rv.NumMethod() == 0:
+		rv.Set(reflect.ValueOf(data))
+		return nil
+	case rv.Type() == timeType:
+		s, ok := data.(string)
+		if !ok {
+			return fail("cannot decode %T into time.Time", data)
+		}
+		layout, ok := tag.Opts["date"]
+		if !ok {
+			return fail("decoding a string into time.Time requires the date option")
+		}
+		t, err := parseDate(s, layout)
+		if err != nil {
+			return fail("%w", err)
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		obj, ok := asDict(data)
+		if !ok {
+			return fail("cannot decode %T into %s", data, rv.Type())
+		}
+		return decodeStruct(obj, rv, path)
+
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fail("cannot decode into %s", rv.Type())
+		}
+		obj, ok := asDict(data)
+		if !ok {
+			return fail("cannot decode %T into %s", data, rv.Type())
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMapWithSize(rv.Type(), len(obj)))
+		}
+		for k, val := range obj {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeValue(val, elem, tag, joinDataPath(path, k)); err != nil {
+				return err
+			}
+			rv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), elem)
+		}
+		return nil
+
+	case reflect.Slice:
+		var list []interface{}
+		switch val := data.(type) {
+		case []interface{}:
+			list = val
+		default:
+			// the files of an insertTo node
+			if obj, ok := asDict(data); ok && rv.Type().Elem().Kind() != reflect.Uint8 {
+				if files, ok := obj["files"].([]interface{}); ok {
+					list = files
+					path = joinDataPath(path, "files")
+					break
+				}
+			}
+			// a single value of a multi value field
+			list = []interface{}{data}
+		}
+		slice := reflect.MakeSlice(rv.Type(), len(list), len(list))
+		for i, item := range list {
+			if err := decodeValue(item, slice.Index(i), tag, joinDataPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+
+	case reflect.String:
+		switch val := data.(type) {
+		case string:
+			rv.SetString(val)
+		case float64, bool:
+			rv.SetString(fmt.Sprint(val))
+		default:
+			return fail("cannot decode %T into %s", data, rv.Type())
+		}
+		return nil
+
+	case reflect.Bool:
+		switch val := data.(type) {
+		case bool:
+			rv.SetBool(val)
+		case string:
+			trueValue, ok := tag.Opts["bool"]
+			if !ok {
+				return fail("decoding a string into %s requires the bool option", rv.Type())
+			}
+			if trueValue != "" {
+				rv.SetBool(strings.TrimSpace(val) == trueValue)
+				return nil
+			}
+			if strings.TrimSpace(val) == "" {
+				rv.SetBool(false)
+				return nil
+			}
+			b, err := strconv.ParseBool(strings.TrimSpace(val))
+			if err != nil {
+				return fail("%w", err)
+			}
+			rv.SetBool(b)
+		default:
+			return fail("cannot decode %T into %s", data, rv.Type())
+		}
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		var num string
+		switch val := data.(type) {
+		case float64:
+			num = strconv.FormatFloat(val, 'f', -1, 64)
+		case int:
+			num = strconv.Itoa(val)
+		case json.Number:
+			num = val.String()
+		case string:
+			if !tag.has("number") && tag.Opts["render"] != "number" {
+				return fail("decoding a string into %s requires the number option", rv.Type())
+			}
+			var err error
+			if num, err = extractNumber(val); err != nil {
+				return fail("%w", err)
+			}
+			if num == "" {
+				if strings.TrimSpace(val) == "" {
+					return nil
+				}
+				return fail("no number in %q", val)
+			}
+		default:
+			return fail("cannot decode %T into %s", data, rv.Type())
+		}
+		return setNumber(rv, num, fail)
+	}
+
+	return fail("cannot decode into %s", rv.Type())
+}
+
+func decodeStruct(obj map[string]interface{}, rv reflect.Value, path string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag, tagged := fieldTag(f)
+		if tag.ID == "-" {
+			continue
+		}
+		if f.Anonymous && !tagged && tag.ID == "" && f.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(obj, rv.Field(i), path); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		id := tag.ID
+		if id == "" {
+			id = f.Name
+		}
+		val, ok := obj[id]
+		if !ok {
+			for k, v := range obj {
+				if strings.EqualFold(k, id) {
+					id, val, ok = k, v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := decodeValue(val, rv.Field(i), tag, joinDataPath(path, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func asDict(data interface{}) (map[string]interface{}, bool) {
+	switch val := data.(type) {
+	case map[string]interface{}:
+		return val, true
+	case DictData:
+		return val, true
+	}
+	return nil, false
+}
+
+var numberPattern = regexp.MustCompile(`[-+]?(\d{1,3}(,\d{3})+(\.\d*)?|\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// extractNumber returns the first number in s, the commas grouping its digits by thousands are dropped.
+// A comma that doesn't, such as the decimal comma of "1,5", makes the number ambiguous and fails.
+func extractNumber(s string) (string, error) {
+	loc := numberPattern.FindStringIndex(s)
+	if loc == nil {
+		return "", nil
+	}
+	rest := s[loc[1]:]
+	if rest != "" && (isDigit(rest[0]) || len(rest) > 1 && rest[0] == ',' && isDigit(rest[1])) {
+		return "", fmt.Errorf("ambiguous number in %q, its commas aren't thousands separators", s)
+	}
+	return strings.ReplaceAll(s[loc[0]:loc[1]], ",", ""), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func setNumber(rv reflect.Value, num string, fail func(format string, args ...interface{}) error) error {
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(num, rv.Type().Bits())
+		if err != nil {
+			return fail("%w", err)
+		}
+		rv.SetFloat(f)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(num, 10, rv.Type().Bits())
+		if err != nil {
+			f, ferr := strconv.ParseFloat(num, 64)
+			if ferr != nil || f != float64(int64(f)) || rv.OverflowInt(int64(f)) {
+				return fail("%w", err)
+			}
+			n = int64(f)
+		}
+		rv.SetInt(n)
+	default:
+		n, err := strconv.ParseUint(strings.TrimPrefix(num, "+"), 10, rv.Type().Bits())
+		if err != nil {
+			f, ferr := strconv.ParseFloat(num, 64)
+			if ferr != nil || f < 0 || f != float64(uint64(f)) || rv.OverflowUint(uint64(f)) {
+				return fail("%w", err)
+			}
+			n = uint64(f)
+		}
+		rv.SetUint(n)
+	}
+	return nil
+}
+
+// the layouts tried by the date option without a value
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+}
+
+// parseDate parses s in the local time zone with layout, or with the dateLayouts if layout is empty
+func parseDate(s, layout string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if layout != "" {
+		return time.ParseInLocation(layout, s, time.Local)
+	}
+	for _, l := range dateLayouts {
+		if t, err := time.ParseInLocation(l, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", s)
+}
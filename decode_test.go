@@ -0,0 +1,126 @@
+package rpa
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type decodeRow struct {
+	Name  string  `crawl:"name"`
+	Price float64 `crawl:"price,number"`
+	Stock int     `crawl:"stock,number"`
+}
+
+type decodeTarget struct {
+	Title    string      `crawl:"title"`
+	Date     time.Time   `crawl:"date,date=2006-01-02"`
+	OnSale   bool        `crawl:"onSale,bool=Yes"`
+	Tags     []string    `crawl:"tags"`
+	Rows     []decodeRow `crawl:"rows"`
+	Form     *struct{ Owner string }
+	Files    []DownloadFileInfo `crawl:"attachments"`
+	PageSize int                `crawl:"pageSize"`
+	Ignored  string             `crawl:"-"`
+}
+
+func TestResultDecode(t *testing.T) {
+	var data DictData
+	err := json.Unmarshal([]byte(`{
+		"title": "Goods",
+		"date": "2024-03-01",
+		"onSale": "Yes",
+		"tags": ["a", "b"],
+		"rows": [
+			{"name": "apple", "price": "¥1,234.50", "stock": "12 pcs"},
+			{"name": "pear", "price": "3", "stock": ""}
+		],
+		"form": {"owner": "bob"},
+		"attachments": {"label": "Files", "downloadId": "zip", "files": [{"name": "a.zip", "url": "https://a.test/a.zip", "error": ""}]},
+		"pageSize": 20,
+		"Ignored": "x"
+	}`), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v decodeTarget
+	if err := (&Result{Data: data}).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Title != "Goods" || !v.OnSale || len(v.Tags) != 2 || v.PageSize != 20 || v.Ignored != "" {
+		t.Errorf("unexpected values %+v", v)
+	}
+	if !v.Date.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected date %v", v.Date)
+	}
+	if len(v.Rows) != 2 || v.Rows[0].Price != 1234.5 || v.Rows[0].Stock != 12 || v.Rows[1].Price != 3 || v.Rows[1].Stock != 0 {
+		t.Errorf("unexpected rows %+v", v.Rows)
+	}
+	if v.Form == nil || v.Form.Owner != "bob" {
+		t.Errorf("unexpected form %+v", v.Form)
+	}
+	if len(v.Files) != 1 || v.Files[0].Name != "a.zip" || v.Files[0].Url != "https://a.test/a.zip" {
+		t.Errorf("unexpected files %+v", v.Files)
+	}
+}
+
+func TestResultDecodeError(t *testing.T) {
+	res := &Result{Data: DictData{
+		"rows": []interface{}{
+			map[string]interface{}{"name": "apple", "price": "1"},
+			map[string]interface{}{"name": "pear", "price": "n/a"},
+		},
+	}}
+	var v decodeTarget
+	err := res.Decode(&v)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "rows/1/price" {
+		t.Errorf("expected an error at rows/1/price, got %v", err)
+	}
+
+	var noOption struct {
+		Price float64 `crawl:"price"`
+	}
+	err = (&Result{Data: DictData{"price": "1"}}).Decode(&noOption)
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "price" {
+		t.Errorf("expected an error at price, got %v", err)
+	}
+}
+
+func Test_extractNumber(t *testing.T) {
+	cases := map[string]string{
+		"¥1,234.50":     "1234.50",
+		"1,234.5":       "1234.5",
+		"12,345,678":    "12345678",
+		"-1,000 pcs":    "-1000",
+		"1, 2 and 3":    "1",
+		"12 pcs":        "12",
+		"about .5":      ".5",
+		"no number":     "",
+		"1.5e3 meters":  "1.5e3",
+		"1234 and 5,67": "1234",
+	}
+	for s, want := range cases {
+		if got, err := extractNumber(s); err != nil || got != want {
+			t.Errorf("extractNumber(%q) = %q, %v, want %q", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"1,5", "€ 1234,56", "1,2345", "1,234,56"} {
+		if got, err := extractNumber(s); err == nil {
+			t.Errorf("extractNumber(%q) = %q, expected an ambiguous number", s, got)
+		}
+	}
+}
+
+func Test_parseCrawlTag(t *testing.T) {
+	tag := parseCrawlTag("price,number,date=2006-01-02")
+	if tag.ID != "price" || !tag.has("number") || tag.Opts["date"] != "2006-01-02" {
+		t.Errorf("unexpected tag %+v", tag)
+	}
+	tag = parseCrawlTag("selector=.price,type=text")
+	if tag.ID != "" || tag.Opts["selector"] != ".price" || tag.Opts["type"] != "text" {
+		t.Errorf("unexpected tag %+v", tag)
+	}
+}
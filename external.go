@@ -0,0 +1,445 @@
+package rpa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-rod/rod"
+)
+
+// Reasons of an external link not being crawled
+const (
+	SkipMaxDepth = "maxDepth"
+	SkipVisited  = "visited"
+	SkipBudget   = "budget"
+)
+
+// crawlSession is shared by a crawl and all of its external crawls
+type crawlSession struct {
+	// tabs is the pool of tabs for the external crawls
+	tabs chan struct{}
+
+	hostLimit int
+	mu        sync.Mutex
+	hosts     map[string]chan struct{}
+
+	maxDepth int
+	budget   int
+	crawled  int
+	visited  map[string]bool
+
+	// cfgs caches the external configs by their keys, cfgMu guards the map only
+	cfgMu sync.Mutex
+	cfgs  map[string]*cfgLoad
+}
+
+// cfgLoad is the loading of an external config, done is closed once cfg or err is set
+type cfgLoad struct {
+	done chan struct{}
+	cfg  *CrawlerConfig
+	err  error
+}
+
+func newCrawlSession(opts CrawlOptions) *crawlSession {
+	return &crawlSession{
+		tabs:      make(chan struct{}, opts.ExternalConcurrency),
+		hostLimit: opts.HostConcurrency,
+		hosts:     make(map[string]chan struct{}),
+		maxDepth:  opts.MaxExternalDepth,
+		budget:    opts.ExternalBudget,
+		visited:   make(map[string]bool),
+		cfgs:      make(map[string]*cfgLoad),
+	}
+}
+
+// visit records the page of link crawled with the config
+func (s *crawlSession) visit(link, cfgPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visited[visitKey(link, cfgPath)] = true
+}
+
+// claim checks the limits of an external link at depth, and records it as visited if it can be crawled.
+// It returns the reason if the link must be skipped.
+func (s *crawlSession) claim(link, cfgPath string, depth int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxDepth > 0 && depth > s.maxDepth {
+		return SkipMaxDepth
+	}
+	key := visitKey(link, cfgPath)
+	if s.visited[key] {
+		return SkipVisited
+	}
+	if s.budget > 0 && s.crawled >= s.budget {
+		return SkipBudget
+	}
+	s.visited[key] = true
+	s.crawled++
+	return ""
+}
+
+// visitKey is the normalized url plus the config path
+func visitKey(link, cfgPath string) string {
+	return normalizeUrl(link) + "|" + normalizeCfgPath(cfgPath)
+}
+
+// normalizeUrl lowercases the scheme and host, drops the default port and the fragment,
+// and sorts the query parameters
+func normalizeUrl(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = u.Query().Encode()
+	return u.String()
+}
+
+func normalizeCfgPath(cfgPath string) string {
+	if cfgPath == "" || isUrl(cfgPath) || strings.HasPrefix(cfgPath, inlineCfgPrefix) {
+		return cfgPath
+	}
+	if p, err := filepath.Abs(cfgPath); err == nil {
+		return p
+	}
+	return cfgPath
+}
+
+// skippedMarker is put in the data node in place of an external link that is not crawled
+func skippedMarker(job extJob, reason string) DictData {
+	return DictData{
+		"url":     job.url,
+		"config":  job.cfgKey(),
+		"skipped": reason,
+	}
+}
+
+// acquire takes a slot of the host of link and a tab of the pool,
+// the returned release func gives them back.
+func (s *crawlSession) acquire(ctx context.Context, link string) (release func(), err error) {
+	var host chan struct{}
+	if s.hostLimit > 0 {
+		u, _ := url.Parse(link)
+		key := ""
+		if u != nil {
+			key = u.Host
+		}
+		s.mu.Lock()
+		host = s.hosts[key]
+		if host == nil {
+			host = make(chan struct{}, s.hostLimit)
+			s.hosts[key] = host
+		}
+		s.mu.Unlock()
+
+		select {
+		case host <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	select {
+	case s.tabs <- struct{}{}:
+	case <-ctx.Done():
+		if host != nil {
+			<-host
+		}
+		return nil, ctx.Err()
+	}
+
+	return func() {
+		<-s.tabs
+		if host != nil {
+			<-host
+		}
+	}, nil
+}
+
+// extJob is an external link to crawl, the crawled data replaces node[item]
+type extJob struct {
+	// cfg is the external config, the path of which is resolved
+	cfg ExternalConfig
+	// base is the source of the config referencing the link
+	base string
+	node map[string]interface{}
+	item string
+	url  string
+	// path is the slash separated path of node[item] in the result data, such as "list/3/link"
+	path string
+}
+
+const inlineCfgPrefix = "inline:"
+
+// cfgKey identifies the config of the job, an embedded config is identified by the hash of its json
+func (job extJob) cfgKey() string {
+	if job.cfg.Inline == nil {
+		return job.cfg.Path
+	}
+	b, _ := json.Marshal(job.cfg.Inline)
+	sum := sha256.Sum256(b)
+	return inlineCfgPrefix + hex.EncodeToString(sum[:])
+}
+
+// cfgSource is the base to resolve the external configs of the linked page,
+// an embedded config shares the source of the config it's embedded in.
+func (job extJob) cfgSource() string {
+	if job.cfg.Inline == nil {
+		return job.cfg.Path
+	}
+	return job.base
+}
+
+// loadExtCfg returns the config of job, each config is loaded and checked once per crawl.
+// The jobs of a config being loaded wait for it, the other configs load meanwhile.
+// A failed load isn't cached, the next job of the config loads it again.
+func (c *Crawler) loadExtCfg(ctx context.Context, sess *crawlSession, job extJob) (*CrawlerConfig, error) {
+	key := job.cfgKey()
+
+	sess.cfgMu.Lock()
+	load, loading := sess.cfgs[key]
+	if !loading {
+		load = &cfgLoad{done: make(chan struct{})}
+		sess.cfgs[key] = load
+	}
+	sess.cfgMu.Unlock()
+
+	if loading {
+		select {
+		case <-load.done:
+			return load.cfg, load.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if job.cfg.Inline != nil {
+		load.cfg, load.err = job.cfg.Inline, checkCfg(job.cfg.Inline)
+	} else {
+		load.cfg, _, load.err = c.fetchCfg(ctx, job.cfg.Path)
+	}
+	if load.err != nil {
+		load.cfg = nil
+		sess.cfgMu.Lock()
+		delete(sess.cfgs, key)
+		sess.cfgMu.Unlock()
+	}
+	close(load.done)
+	return load.cfg, load.err
+}
+
+// collectExtJobs lists the external links of result in a stable order,
+// the external config paths are resolved against cfgFilePath by join.
+func collectExtJobs(result *Result, cfgFilePath string, join func(basePath, refPath string) (string, error)) ([]extJob, error) {
+	keys := make([]string, 0, len(result.ExternalSection))
+	for k := range result.ExternalSection {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var jobs []extJob
+	for _, k := range keys {
+		extItem := result.ExternalSection[k]
+		if extItem.Config.IsZero() {
+			continue
+		}
+		extCfg := extItem.Config
+		if extCfg.Inline == nil {
+			p, err := join(cfgFilePath, extCfg.Path)
+			if err != nil {
+				return nil, err
+			}
+			extCfg.Path = p
+		}
+		cc := extItem.Connect
+		var itemName string
+
+		var resNode interface{}
+		resNode = result.Data
+
+		if cc != "" {
+			var err error
+			resNode, itemName, err = GetDictAndLastSegmentByPath(result.Data, cc)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		dir := ""
+		if i := strings.LastIndex(strings.Trim(cc, "/"), "/"); i >= 0 {
+			dir = strings.Trim(cc, "/")[:i]
+		}
+		addJob := func(node map[string]interface{}, path string) {
+			if link, ok := node[itemName].(string); ok && link != "" {
+				jobs = append(jobs, extJob{cfg: extCfg, base: cfgFilePath, node: node, item: itemName, url: link, path: path})
+			}
+		}
+
+		switch val := resNode.(type) {
+		case nil:
+		case []interface{}:
+			for i, resExtNode := range val {
+				if extNode, ok := resExtNode.(map[string]interface{}); ok {
+					addJob(extNode, joinDataPath(dir, strconv.Itoa(i), itemName))
+				}
+			}
+		case map[string]interface{}:
+			addJob(val, joinDataPath(dir, itemName))
+		case DictData:
+			addJob(val, joinDataPath(dir, itemName))
+		default:
+			return nil, fmt.Errorf("unexpected externalSection type %T", resNode)
+		}
+	}
+	return jobs, nil
+}
+
+// joinDataPath joins the non-empty segments of a data path with slashes
+func joinDataPath(segments ...string) string {
+	var parts []string
+	for _, s := range segments {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// crawlExternals crawls the external links of result at depth through the tab pool of sess,
+// the crawled data are put back in the same positions of result.Data once all the links are done.
+// The links exceeding the limits of sess are replaced by a marker telling why they are skipped.
+// A link failing to crawl keeps its url, the errors of the external crawls are added to result.Errors
+// under the path of the link. In strict mode the other links are canceled on the first error.
+func (c *Crawler) crawlExternals(ctx context.Context, result *Result, cfgFilePath string, depth int, opts CrawlOptions, sess *crawlSession) error {
+	if result.ExternalSection == nil {
+		return nil
+	}
+
+	jobs, err := collectExtJobs(result, cfgFilePath, c.joinCfgPath)
+	if err != nil {
+		return err
+	}
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	values := make([]interface{}, len(jobs))
+	done := make([]bool, len(jobs))
+	errs := make([][]CrawlError, len(jobs))
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		if reason := sess.claim(job.url, job.cfgKey(), depth); reason != "" {
+			values[i], done[i] = skippedMarker(job, reason), true
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], done[i], errs[i] = c.crawlExternal(jobCtx, jobs[i], depth, opts, sess)
+			if opts.Strict && len(errs[i]) > 0 {
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var extErrs []CrawlError
+	for i, job := range jobs {
+		if done[i] {
+			job.node[job.item] = values[i]
+		}
+		extErrs = append(extErrs, errs[i]...)
+	}
+	result.Errors = append(result.Errors, extErrs...)
+
+	if err = canceledOr(ctx, nil); err != nil {
+		return err
+	}
+	if opts.Strict {
+		return firstError(extErrs)
+	}
+	return nil
+}
+
+// crawlExternal crawls one external link, the tab is given back to the pool
+// before crawling the external links of the linked page.
+// It returns false if the link is not crawled, because ctx is done or the crawl failed,
+// along with the errors of the crawl under the path of the link.
+func (c *Crawler) crawlExternal(ctx context.Context, job extJob, depth int, opts CrawlOptions, sess *crawlSession) (interface{}, bool, []CrawlError) {
+	release, err := sess.acquire(ctx, job.url)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	extOpts := opts
+	extOpts.CloseTab = true
+
+	var res *Result
+	cfg, err := c.loadExtCfg(ctx, sess, job)
+	if err == nil {
+		var page *rod.Page
+		page, err = c.openPage(c.Browser, job.url)
+		if err == nil {
+			res, err = c.crawlPage(ctx, page, cfg, extOpts)
+		}
+	}
+	release()
+
+	// a CrawlError of strict mode is already in res.Errors
+	var failure *CrawlError
+	var crawlErr CrawlError
+	if err != nil && ctx.Err() == nil && !errors.As(err, &crawlErr) {
+		failure = &CrawlError{Stage: StageExternal, Message: fmt.Sprintf("crawling %s: %s", job.url, err)}
+	}
+
+	if res != nil {
+		// a failed or canceled crawl still keeps its partial data
+		if err == nil {
+			_ = c.crawlExternals(ctx, res, job.cfgSource(), depth+1, extOpts, sess)
+		}
+		if failure != nil {
+			res.Errors = append(res.Errors, *failure)
+		}
+		return res.Data, true, prefixErrors(job.path, res.Errors)
+	}
+	if failure == nil {
+		return nil, false, nil
+	}
+	return nil, false, prefixErrors(job.path, []CrawlError{*failure})
+}
+
+// prefixErrors moves the paths of errs under prefix
+func prefixErrors(prefix string, errs []CrawlError) []CrawlError {
+	prefixed := make([]CrawlError, 0, len(errs))
+	for _, e := range errs {
+		e.Path = joinDataPath(prefix, e.Path)
+		prefixed = append(prefixed, e)
+	}
+	return prefixed
+}
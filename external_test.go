@@ -0,0 +1,216 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_collectExtJobs(t *testing.T) {
+	rows := []interface{}{
+		map[string]interface{}{"title": "a", "link": "https://a.test/1"},
+		map[string]interface{}{"title": "b", "link": ""},
+		map[string]interface{}{"title": "c", "link": "https://b.test/2"},
+	}
+	result := &Result{
+		Data: DictData{
+			"list":   rows,
+			"detail": "https://c.test/3",
+		},
+		ExternalSection: map[string]ExternalResult{
+			"/list/link": {Config: ExternalConfig{Path: "detail.json"}, Connect: "/list/link", ID: "link"},
+			"/detail":    {Config: ExternalConfig{Path: "detail.json"}, Connect: "/detail", ID: "detail"},
+		},
+	}
+
+	jobs, err := collectExtJobs(result, "/cfg/master.json", joinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	detailCfg, _ := filepath.Abs(filepath.Join("/cfg", "detail.json"))
+	var urls, paths []string
+	for _, job := range jobs {
+		urls = append(urls, job.url)
+		paths = append(paths, job.path)
+		if job.cfg.Path != detailCfg {
+			t.Errorf("config not resolved against the master config: %s", job.cfg.Path)
+		}
+	}
+	expected := []string{"https://c.test/3", "https://a.test/1", "https://b.test/2"}
+	if len(urls) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, urls)
+	}
+	for i := range expected {
+		if urls[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, urls)
+			break
+		}
+	}
+	expectedPaths := []string{"detail", "list/0/link", "list/2/link"}
+	for i := range expectedPaths {
+		if paths[i] != expectedPaths[i] {
+			t.Errorf("expected %v, got %v", expectedPaths, paths)
+			break
+		}
+	}
+}
+
+func Test_crawlSessionAcquire(t *testing.T) {
+	sess := newCrawlSession(CrawlOptions{ExternalConcurrency: 4, HostConcurrency: 2})
+
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := sess.acquire(context.Background(), "https://same.host/page")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning > 2 {
+		t.Errorf("host concurrency exceeded: %d", maxRunning)
+	}
+
+	// the host is full, a canceled acquire must not wait forever
+	r1, _ := sess.acquire(context.Background(), "https://same.host/page")
+	r2, _ := sess.acquire(context.Background(), "https://same.host/page")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sess.acquire(ctx, "https://same.host/page"); err == nil {
+		t.Error("expected an error when acquiring with a canceled context")
+	}
+	r1()
+	r2()
+}
+
+func Test_normalizeUrl(t *testing.T) {
+	cases := map[string]string{
+		"HTTPS://Example.COM:443/a?b=2&a=1#top": "https://example.com/a?a=1&b=2",
+		"http://example.com":                    "http://example.com/",
+		"http://example.com:8080/x":             "http://example.com:8080/x",
+	}
+	for in, expected := range cases {
+		if got := normalizeUrl(in); got != expected {
+			t.Errorf("normalizeUrl(%q) = %q, expected %q", in, got, expected)
+		}
+	}
+}
+
+func Test_crawlSessionClaim(t *testing.T) {
+	sess := newCrawlSession(CrawlOptions{MaxExternalDepth: 2, ExternalBudget: 2}.withDefaults())
+	sess.visit("https://a.test/", "master.json")
+
+	if r := sess.claim("https://a.test/#x", "master.json", 1); r != SkipVisited {
+		t.Errorf("expected %s, got %q", SkipVisited, r)
+	}
+	if r := sess.claim("https://a.test/", "detail.json", 1); r != "" {
+		t.Errorf("a different config should be crawled, got %q", r)
+	}
+	if r := sess.claim("https://a.test/d", "detail.json", 3); r != SkipMaxDepth {
+		t.Errorf("expected %s, got %q", SkipMaxDepth, r)
+	}
+	if r := sess.claim("https://a.test/d", "detail.json", 2); r != "" {
+		t.Errorf("expected to be crawled, got %q", r)
+	}
+	if r := sess.claim("https://a.test/e", "detail.json", 2); r != SkipBudget {
+		t.Errorf("expected %s, got %q", SkipBudget, r)
+	}
+}
+
+func Test_ExternalConfigJSON(t *testing.T) {
+	var ext ExternalResult
+	err := json.Unmarshal([]byte(`{"id":"a","connect":"/a","config":"detail.json"}`), &ext)
+	if err != nil || ext.Config.Path != "detail.json" || ext.Config.Inline != nil {
+		t.Fatalf("unexpected path config %+v, %v", ext.Config, err)
+	}
+
+	err = json.Unmarshal([]byte(`{"id":"a","connect":"/a","config":{"pageLoad":{"wait":"wait"},"dataSection":[{"id":"title","selector":"h1","itemType":"text"}]}}`), &ext)
+	if err != nil || ext.Config.Inline == nil || len(ext.Config.Inline.DataSection) != 1 {
+		t.Fatalf("unexpected embedded config %+v, %v", ext.Config, err)
+	}
+
+	b, err := json.Marshal(ext.Config)
+	if err != nil || !strings.HasPrefix(string(b), "{") {
+		t.Errorf("embedded config should marshal to an object: %s, %v", b, err)
+	}
+
+	job1 := extJob{cfg: ext.Config}
+	job2 := extJob{cfg: ext.Config, base: "/other"}
+	if job1.cfgKey() != job2.cfgKey() || !strings.HasPrefix(job1.cfgKey(), inlineCfgPrefix) {
+		t.Errorf("unexpected embedded config keys %q, %q", job1.cfgKey(), job2.cfgKey())
+	}
+}
+
+func Test_loadExtCfg(t *testing.T) {
+	release := make(chan struct{})
+	var slowCalls int32
+	c := &Crawler{CfgFetcher: func(path string) (*CrawlerConfig, error) {
+		if path == "slow.json" {
+			atomic.AddInt32(&slowCalls, 1)
+			<-release
+		}
+		return &CrawlerConfig{DataSection: DataNodes{&ValueItem{ConfigNode: ConfigNode{ID: "title", Selector: "h1"}, ItemType: ItemText}}}, nil
+	}}
+	sess := newCrawlSession(CrawlOptions{})
+	job := func(path string) extJob {
+		return extJob{cfg: ExternalConfig{Path: path}}
+	}
+
+	var wg sync.WaitGroup
+	cfgs := make([]*CrawlerConfig, 3)
+	for i := range cfgs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg, err := c.loadExtCfg(context.Background(), sess, job("slow.json"))
+			if err != nil {
+				t.Error(err)
+			}
+			cfgs[i] = cfg
+		}(i)
+	}
+
+	// another config loads while the slow one is being fetched
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := c.loadExtCfg(context.Background(), sess, job("fast.json")); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the load of a config waited for another config")
+	}
+
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&slowCalls); n != 1 {
+		t.Errorf("expected the slow config fetched once, got %d", n)
+	}
+	if cfgs[0] == nil || cfgs[0] != cfgs[1] || cfgs[1] != cfgs[2] {
+		t.Errorf("expected the same config for every job, got %v", cfgs)
+	}
+}
@@ -0,0 +1,65 @@
+package rpa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-rod/rod"
+)
+
+// FillErrors is the items of the data that FillPage failed to write into the page
+type FillErrors []CrawlError
+
+func (e FillErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "fill failed:\n" + strings.Join(msgs, "\n")
+}
+
+// FillPage writes data into the page with the items of the dataSection of cfgOrFile, the reverse of crawling the page.
+//
+// The data has the shape of Result.Data, the nodes missing in it are left untouched.
+// A textBox is set to its value, the radioBox and the checkBoxes matching the values are checked and the others unchecked,
+// and the option of a dropBox is selected by its text, or by its value if valueProper is "value".
+// The input and change events are fired as if the user did it. The text and download items are skipped,
+// and the renders are not reversed: a value is written as it would be crawled before its valueRender.
+// The rows of a list are written into the elements of the list in order.
+//
+// The items that can't be written are returned as FillErrors, with the stage StageFill, the other items are still written.
+func (c *Crawler) FillPage(page *rod.Page, cfgOrFile interface{}, data DictData) error {
+	cfg, _, err := c.resolveCfg(context.Background(), cfgOrFile, "")
+	if err != nil {
+		return err
+	}
+	roots, err := shadowRootsFor(cfg)
+	if err != nil {
+		return err
+	}
+	defer roots.release()
+	rootsArg, err := roots.arg(page)
+	if err != nil {
+		return err
+	}
+
+	jsCode := fmt.Sprintf(`
+	(cfg, data, roots)=>{
+		%s;
+		return fill(cfg, data, roots);
+	}`, crawlerJs)
+
+	res, err := page.Eval(jsCode, cfg, data, rootsArg)
+	if err != nil {
+		return err
+	}
+	var errs FillErrors
+	if err = res.Value.Unmarshal(&errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
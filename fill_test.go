@@ -0,0 +1,61 @@
+package rpa_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	rpa "github.com/rpdg/rod-helper"
+	"github.com/rpdg/rod-helper/rpatest"
+)
+
+func TestFillPage(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+	cfgFile := filepath.Join("testdata", "site", "fill.json")
+	page := h.Page("fill.html")
+	page.MustWaitLoad()
+
+	data := rpa.DictData{"order": map[string]interface{}{
+		"customer": "ACME Ltd.",
+		"note":     "leave at the door",
+		"ship":     "express",
+		"gift":     []interface{}{"card"},
+		"currency": "Euro",
+		"lines": []interface{}{
+			map[string]interface{}{"item": "Apple", "qty": "3"},
+			map[string]interface{}{"item": "Pear", "qty": "1"},
+		},
+	}}
+	if err := h.Crawler.FillPage(page, cfgFile, data); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := h.Crawler.CrawlPage(page, cfgFile, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := data["order"].(map[string]interface{})
+	// the text items are crawled from what the events of the inputs have written
+	want["echo"] = "ACME Ltd."
+	want["currencyCode"] = "eur"
+	if got := res.Data["order"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	err = h.Crawler.FillPage(page, cfgFile, rpa.DictData{"order": map[string]interface{}{
+		"currency": "Yen",
+		"lines":    []interface{}{nil, nil, map[string]interface{}{"item": "Plum"}},
+	}})
+	var fillErrs rpa.FillErrors
+	if !errors.As(err, &fillErrs) {
+		t.Fatalf("expected FillErrors, got %v", err)
+	}
+	var paths []string
+	for _, e := range fillErrs {
+		paths = append(paths, e.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"order/currency", "order/lines/2"}) {
+		t.Errorf("unexpected errors %v", fillErrs)
+	}
+}
@@ -0,0 +1,177 @@
+package rpa_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+	rpa "github.com/rpdg/rod-helper"
+	"github.com/rpdg/rod-helper/rpatest"
+)
+
+// TestCrawlFixtures crawls the pages of testdata/site with their configs, the results must match the golden files.
+// A fixture is a page name.html with its config name.json and its result name.golden.json.
+func TestCrawlFixtures(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+
+	for _, engine := range []rpa.Engine{rpa.EngineJS, rpa.EngineNative} {
+		for _, name := range []string{"downloads", "frames", "xpath", "labels", "crossframe", "shadow", "actions", "pages"} {
+			t.Run(string(engine)+"/"+name, func(t *testing.T) {
+				opts := rpa.CrawlOptions{AutoDownload: true, Engine: engine}
+				res, err := h.Crawl(name+".html", filepath.Join("testdata", "site", name+".json"), opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.Golden(filepath.Join("testdata", "site", name+".golden.json"), res)
+			})
+		}
+	}
+
+	// the served attachments are saved under the id of the download section
+	for _, file := range []string{"report.txt", "data.csv"} {
+		got, err := os.ReadFile(filepath.Join(h.DownloadDir, "reports", file))
+		if err != nil {
+			t.Errorf("%s not downloaded: %v", file, err)
+			continue
+		}
+		want, _ := os.ReadFile(filepath.Join("testdata", "site", "attachments", file))
+		if string(got) != string(want) {
+			t.Errorf("unexpected content of %s: %q", file, got)
+		}
+	}
+}
+
+// TestNumberRender crawls with the number render of the crawl tags, it reads the numbers like Result.Decode
+func TestNumberRender(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+	cfg, err := rpa.GenerateConfig(struct {
+		Total float64 `crawl:"selector=.total,render=number"`
+		Rate  float64 `crawl:"selector=.rate,render=number"`
+	}{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.Crawl("numbers.html", cfg, rpa.CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data["total"] != "1234.5" {
+		t.Errorf("expected 1234.5, got %v", res.Data["total"])
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Path != "rate" || res.Errors[0].Stage != rpa.StageValueRender {
+		t.Errorf("expected the decimal comma of rate reported, got %v", res.Errors)
+	}
+}
+
+// TestExportImportState carries the cookies and the web storage of a browser over to another one with a state file
+func TestExportImportState(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+	page := h.Page("state.html")
+	page.MustWaitLoad()
+	page.MustEval(`() => {
+		document.cookie = 'sid=abc; path=/; max-age=3600';
+		// a session cookie has no expiry
+		document.cookie = 'lang=en; path=/';
+		localStorage.setItem('token', 'xyz');
+		sessionStorage.setItem('step', '2');
+	}`)
+
+	file := filepath.Join(t.TempDir(), "state.json")
+	opts := rpa.StateOptions{Origins: []string{h.Server.URL}, Key: []byte("secret")}
+	if err := h.Crawler.ExportState(file, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Crawler = &rpa.Crawler{Browser: rpatest.Browser(t)}
+	if err := h.Crawler.ImportState(file, opts); err != nil {
+		t.Fatal(err)
+	}
+	res, err := h.Crawl("state.html", filepath.Join("testdata", "site", "state.json"), rpa.CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := rpa.DictData{"cookie": "abc", "local": "xyz", "session": "2", "sessionCookie": "en"}
+	if !reflect.DeepEqual(res.Data, want) {
+		t.Errorf("expected %v, got %v", want, res.Data)
+	}
+
+	// the tabs of the external links get the imported sessionStorage as well
+	var linkCfg rpa.CrawlerConfig
+	err = json.Unmarshal([]byte(`{"dataSection": [{
+		"id": "state", "selector": "a.state", "itemType": "text", "valueProper": "href",
+		"valueRender": "return new URL(val, location.href).href",
+		"external": {"config": {"dataSection": [{"id": "session", "selector": ".session", "itemType": "text"}]}}
+	}]}`), &linkCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = h.Crawl("state-link.html", &linkCfg, rpa.CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := res.Data["state"]; !reflect.DeepEqual(state, rpa.DictData{"session": "2"}) {
+		t.Errorf("expected the external page to read the imported sessionStorage, got %v", state)
+	}
+}
+
+// TestIncognito crawls in incognito contexts that don't see the cookies of the browser, and are disposed of afterwards
+func TestIncognito(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+	cfgFile := filepath.Join("testdata", "site", "state.json")
+	page := h.Page("state.html")
+	page.MustWaitLoad()
+	page.MustEval(`() => { document.cookie = 'sid=abc; path=/; max-age=3600'; }`)
+
+	contexts := func() int {
+		res, err := proto.TargetGetBrowserContexts{}.Call(h.Crawler.Browser)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(res.BrowserContextIDs)
+	}
+
+	res, err := h.Crawl("state.html", cfgFile, rpa.CrawlOptions{Incognito: &rpa.IncognitoOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data["cookie"] != "" {
+		t.Errorf("expected no cookie in the incognito context, got %v", res.Data["cookie"])
+	}
+	if n := contexts(); n != 0 {
+		t.Errorf("expected the context disposed of, %d left", n)
+	}
+
+	// the tab is disposed of with its context, it's not returned even if CloseTab is false
+	_, p, err := h.Crawler.CrawlUrlWithOptions(context.Background(), h.URL("state.html"), cfgFile,
+		rpa.CrawlOptions{Incognito: &rpa.IncognitoOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != nil {
+		t.Errorf("expected no page of a disposed context")
+	}
+
+	file := filepath.Join(t.TempDir(), "state.json")
+	if err = h.Crawler.ExportState(file, rpa.StateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := h.Crawler.Incognito(rpa.IncognitoOptions{StateFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _, err = w.CrawlUrlWithOptions(context.Background(), h.URL("state.html"), cfgFile, rpa.CrawlOptions{CloseTab: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data["cookie"] != "abc" {
+		t.Errorf("expected the imported cookie, got %v", res.Data["cookie"])
+	}
+	w.Close()
+	if n := contexts(); n != 0 {
+		t.Errorf("expected the context disposed of, %d left", n)
+	}
+}
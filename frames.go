@@ -0,0 +1,210 @@
+package rpa
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// queryUnderJS runs the queryElems of commonJSCode under this, or under the document if this is the window
+var queryUnderJS = fmt.Sprintf(`function (selector, roots) {
+	%s
+	closedRoots = roots;
+	return queryElems(selector, this instanceof Node ? this : document);
+}`, commonJSCode)
+
+// queryUnder returns the elements of the selector under scope, or under the document of page if scope is nil,
+// the closed shadow roots are read from roots
+func queryUnder(page *rod.Page, scope *rod.Element, selector string, roots *shadowRoots) (rod.Elements, error) {
+	var rootsArg interface{}
+	if usesShadow(selector) {
+		var err error
+		if rootsArg, err = roots.arg(page); err != nil {
+			return nil, err
+		}
+	}
+	if scope != nil {
+		return scope.ElementsByJS(rod.Eval(queryUnderJS, selector, rootsArg))
+	}
+	return page.ElementsByJS(rod.Eval(queryUnderJS, selector, rootsArg))
+}
+
+// framePage returns the page of the iframe element host.
+// An iframe of another site runs in its own process, it's attached as a target of the browser by the id of its frame.
+func framePage(host *rod.Element) (*rod.Page, error) {
+	node, err := host.Describe(1, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.ContentDocument != nil {
+		return host.Frame()
+	}
+	if node.FrameID == "" {
+		return nil, fmt.Errorf("<%s> is not a frame", strings.ToLower(node.NodeName))
+	}
+	page := host.Page()
+	// the frames take the device of their page, they can't emulate one
+	return page.Browser().Context(page.GetContext()).NoDefaultDevice().PageFromTarget(proto.TargetTargetID(node.FrameID))
+}
+
+// crossFrame resolves the leading :frame() pseudos of the selector under scope, or under the document of page if scope is nil.
+// If one of the frames can't be reached from the page script, such as a cross-origin iframe,
+// it returns the page of the innermost frame and the rest of the selector, otherwise a nil page.
+func crossFrame(page *rod.Page, scope *rod.Element, selector string, roots *shadowRoots) (*rod.Page, string, error) {
+	crossed := false
+	for {
+		pseudo, arg, rest, err := leadingPseudo(selector)
+		if err != nil {
+			return nil, "", err
+		}
+		if pseudo != "frame" {
+			break
+		}
+		hosts, err := queryUnder(page, scope, arg, roots)
+		if err != nil || len(hosts) == 0 {
+			return nil, "", err
+		}
+		reachable, err := hosts[0].Eval(`() => this.contentDocument !== null`)
+		if err != nil {
+			return nil, "", err
+		}
+		crossed = crossed || !reachable.Value.Bool()
+
+		if page, err = framePage(hosts[0]); err != nil {
+			return nil, "", err
+		}
+		scope = nil
+		selector = rest
+	}
+	if !crossed {
+		return nil, "", nil
+	}
+	return page, selector, nil
+}
+
+// crawlCrossFrames crawls the nodes of a :frame() selector that crawler.js can't reach in the page,
+// each one is crawled in the page of its frame and its data is put in place of the empty data crawled by crawler.js.
+// The frames under a section having a domRender, a filterRender or a dataRender are not reached,
+// as its data may not follow its elements. The nodes are crawled in the frames with the engine.
+func crawlCrossFrames(page *rod.Page, cfg *CrawlerConfig, res *Result, engine Engine, roots *shadowRoots) {
+	if res.Data == nil {
+		res.Data = make(DictData)
+	}
+	crawlFramesIn(page, nil, cfg.DataSection, res.Data, "", "", res, engine, roots)
+}
+
+// crawlFramesIn crawls the cross-origin frames of nodes under scope, data is the data of the section at dataPath,
+// and cncPath is the path of the section in the connect of the external links.
+func crawlFramesIn(page *rod.Page, scope *rod.Element, nodes DataNodes, data map[string]interface{}, dataPath, cncPath string, res *Result, engine Engine, roots *shadowRoots) {
+	for _, node := range nodes {
+		cn := node.Node()
+		if !hasFrameSelector(node) {
+			continue
+		}
+		nodePath := joinDataPath(dataPath, cn.ID)
+
+		if strings.HasPrefix(cn.Selector, ":frame(") {
+			fp, rest, err := crossFrame(page, scope, cn.Selector, roots)
+			if err == nil && fp != nil {
+				err = crawlInFrame(fp, node, rest, data, dataPath, cncPath, res, engine)
+			}
+			if err != nil {
+				res.Errors = append(res.Errors, CrawlError{Path: nodePath, Stage: StageFrame, Selector: cn.Selector, Message: err.Error()})
+			}
+			continue
+		}
+
+		sec, ok := node.(*DataSection)
+		if !ok || sec.DomRender != "" || sec.FilterRender != "" || sec.DataRender != "" {
+			continue
+		}
+		elems, err := queryUnder(page, scope, sec.Selector, roots)
+		if err != nil {
+			res.Errors = append(res.Errors, CrawlError{Path: nodePath, Stage: StageFrame, Selector: sec.Selector, Message: err.Error()})
+			continue
+		}
+		secCnc := cncPath + "/" + sec.ID
+		if sec.SectionType == SectionList {
+			rows, _ := data[sec.ID].([]interface{})
+			for i, row := range rows {
+				if rowData, ok := row.(map[string]interface{}); ok && i < len(elems) {
+					crawlFramesIn(page, elems[i], sec.Items, rowData, joinDataPath(nodePath, fmt.Sprint(i)), secCnc, res, engine, roots)
+				}
+			}
+		} else if secData, ok := data[sec.ID].(map[string]interface{}); ok && len(elems) > 0 {
+			crawlFramesIn(page, elems[0], sec.Items, secData, nodePath, secCnc, res, engine, roots)
+		}
+	}
+}
+
+// crawlInFrame crawls node with the selector in the page of its frame, and merges the result into the section of the node
+func crawlInFrame(framePage *rod.Page, node DataNode, selector string, data map[string]interface{}, dataPath, cncPath string, res *Result, engine Engine) error {
+	var inFrame DataNode
+	switch n := node.(type) {
+	case *ValueItem:
+		item := *n
+		item.Selector = selector
+		inFrame = &item
+	case *DataSection:
+		sec := *n
+		sec.Selector = selector
+		inFrame = &sec
+	default:
+		return errors.New("unknown node type")
+	}
+
+	sub, err := extract(framePage, &CrawlerConfig{DataSection: DataNodes{inFrame}}, engine)
+	if err != nil {
+		return err
+	}
+
+	id := node.Node().ID
+	data[id] = sub.Data[id]
+
+	nodePath := joinDataPath(dataPath, id)
+	errs := res.Errors[:0]
+	for _, e := range res.Errors {
+		if e.Path != nodePath && !strings.HasPrefix(e.Path, nodePath+"/") {
+			errs = append(errs, e)
+		}
+	}
+	for _, e := range sub.Errors {
+		e.Path = joinDataPath(dataPath, e.Path)
+		errs = append(errs, e)
+	}
+	res.Errors = errs
+
+	for _, ext := range sub.ExternalSection {
+		if cncPath != "" {
+			// crawler.js connects the items at the root as //id, and the nodes of a section as /section/id
+			if _, ok := node.(*ValueItem); ok {
+				ext.Connect = cncPath + "/" + strings.TrimPrefix(ext.Connect, "//")
+			} else {
+				ext.Connect = cncPath + ext.Connect
+			}
+		}
+		if res.ExternalSection == nil {
+			res.ExternalSection = make(map[string]ExternalResult)
+		}
+		res.ExternalSection[ext.Connect] = ext
+	}
+	return nil
+}
+
+// hasFrameSelector reports whether a selector of the node or of its items starts with :frame()
+func hasFrameSelector(node DataNode) bool {
+	if strings.HasPrefix(node.Node().Selector, ":frame(") {
+		return true
+	}
+	if sec, ok := node.(*DataSection); ok {
+		for _, item := range sec.Items {
+			if hasFrameSelector(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
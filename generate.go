@@ -0,0 +1,379 @@
+package rpa
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// renderPresets are the valueRender shortcuts of the render option of the crawl tag
+var renderPresets = map[string]string{
+	"number": numberRender,
+	"trim":   `return val == null ? val : String(val).trim();`,
+}
+
+// numberRender is extractNumber in JavaScript, it shares its pattern so a number is read the same by the crawl and by Decode.
+// An ambiguous number is thrown, it's reported as a valueRender error.
+var numberRender = fmt.Sprintf(`var s = String(val == null ? '' : val);
+var m = s.match(/%s/);
+if (!m) return '';
+if (/^,?\d/.test(s.slice(m.index + m[0].length))) throw new Error('ambiguous number in ' + JSON.stringify(s) + ', its commas aren\'t thousands separators');
+return m[0].replace(/,/g, '');`, numberPattern)
+
+// genShape is how a field is crawled
+type genShape int
+
+const (
+	// a single value item
+	shapeValue genShape = iota
+	// a multi value item, such as a checkBox
+	shapeValues
+	// a form section
+	shapeForm
+	// a list section
+	shapeList
+)
+
+// genField is a field of a struct generating a config node,
+// it's built from a reflect.Type by GenerateConfig or from the Go source by GenerateConfigFromFiles.
+type genField struct {
+	name   string
+	tag    reflect.StructTag
+	shape  genShape
+	fields []genField
+}
+
+// GenerateConfig generates a CrawlerConfig skeleton from the `crawl` tags of a struct,
+// v is a struct, a pointer to a struct, or its reflect.Type.
+//
+// The tag options of a field are, all optional:
+//
+//	selector=.price    the selector, selectors having commas are not supported
+//	type=text          the itemType, defaults to checkBox for a slice of strings and text otherwise
+//	render=number      the valueRender, either a preset (number, trim) or a JavaScript function body without commas
+//	proper=href        the valueProper
+//	label=Price        the label, defaults to the field name
+//	section=form       the sectionType, defaults to form for a struct and list for a slice of structs
+//	filter=...         the filterRender of a section
+//	download=zip       the downloadId of a download item, a downloadSection skeleton is added for it
+//	external=d.json    the config of the external link, the field is a value item even if it's a struct
+//
+// The id is the first element of the tag, or the field name with its first letter lowercased,
+// so that Result.Decode maps the crawled data back into the struct.
+func GenerateConfig(v interface{}) (*CrawlerConfig, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("generate config requires a struct, got %v", t)
+	}
+	fields, err := reflectFields(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	return buildConfig(fields)
+}
+
+// GenerateConfigFromFiles generates a CrawlerConfig skeleton like GenerateConfig
+// from the struct type typeName declared in the Go source files.
+func GenerateConfigFromFiles(typeName string, files ...string) (*CrawlerConfig, error) {
+	fset := token.NewFileSet()
+	types := make(map[string]*ast.StructType)
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := ts.Type.(*ast.StructType); ok {
+					types[ts.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+	st, ok := types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("struct type %s not found", typeName)
+	}
+	fields, err := astFields(st, types, []string{typeName})
+	if err != nil {
+		return nil, err
+	}
+	return buildConfig(fields)
+}
+
+func reflectFields(t reflect.Type, parents []reflect.Type) ([]genField, error) {
+	for _, p := range parents {
+		if p == t {
+			return nil, fmt.Errorf("recursive struct type %s", t)
+		}
+	}
+	parents = append(parents, t)
+
+	var fields []genField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && f.Tag.Get("crawl") == "" && ft.Kind() == reflect.Struct {
+			embedded, err := reflectFields(ft, parents)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if !f.IsExported() || f.Tag.Get("crawl") == "-" {
+			continue
+		}
+
+		gf := genField{name: f.Name, tag: f.Tag, shape: shapeValue}
+		elem := ft
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			elem = ft.Elem()
+			for elem.Kind() == reflect.Pointer {
+				elem = elem.Elem()
+			}
+			gf.shape = shapeValues
+		}
+		if elem.Kind() == reflect.Struct && elem != timeType && elem != reflect.TypeOf(DownloadFileInfo{}) {
+			if gf.shape == shapeValues {
+				gf.shape = shapeList
+			} else {
+				gf.shape = shapeForm
+			}
+			if !parseCrawlTag(f.Tag.Get("crawl")).has("external") {
+				var err error
+				gf.fields, err = reflectFields(elem, parents)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		fields = append(fields, gf)
+	}
+	return fields, nil
+}
+
+func astFields(st *ast.StructType, types map[string]*ast.StructType, parents []string) ([]genField, error) {
+	var fields []genField
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			raw, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(raw)
+		}
+
+		expr := f.Type
+		for {
+			if star, ok := expr.(*ast.StarExpr); ok {
+				expr = star.X
+				continue
+			}
+			break
+		}
+
+		if len(f.Names) == 0 {
+			// an embedded struct of the same source
+			if ident, ok := expr.(*ast.Ident); ok && tag.Get("crawl") == "" {
+				if embedded, ok := types[ident.Name]; ok {
+					sub, err := astFields(embedded, types, append(parents, ident.Name))
+					if err != nil {
+						return nil, err
+					}
+					fields = append(fields, sub...)
+				}
+			}
+			continue
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() || tag.Get("crawl") == "-" {
+				continue
+			}
+			gf := genField{name: name.Name, tag: tag, shape: shapeValue}
+			elem := expr
+			if arr, ok := expr.(*ast.ArrayType); ok {
+				if ident, ok := arr.Elt.(*ast.Ident); !ok || ident.Name != "byte" {
+					elem = arr.Elt
+					if star, ok := elem.(*ast.StarExpr); ok {
+						elem = star.X
+					}
+					gf.shape = shapeValues
+				}
+			}
+
+			var sub *ast.StructType
+			subName := ""
+			switch e := elem.(type) {
+			case *ast.StructType:
+				sub = e
+			case *ast.Ident:
+				sub, subName = types[e.Name], e.Name
+			}
+			if sub != nil {
+				if gf.shape == shapeValues {
+					gf.shape = shapeList
+				} else {
+					gf.shape = shapeForm
+				}
+				if !parseCrawlTag(tag.Get("crawl")).has("external") {
+					for _, p := range parents {
+						if subName != "" && p == subName {
+							return nil, fmt.Errorf("recursive struct type %s", subName)
+						}
+					}
+					var err error
+					gf.fields, err = astFields(sub, types, append(parents, subName))
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+			fields = append(fields, gf)
+		}
+	}
+	return fields, nil
+}
+
+func buildConfig(fields []genField) (*CrawlerConfig, error) {
+	cfg := &CrawlerConfig{}
+	nodes, err := buildNodes(cfg, fields, "")
+	if err != nil {
+		return nil, err
+	}
+	cfg.DataSection = nodes
+	return cfg, nil
+}
+
+func buildNodes(cfg *CrawlerConfig, fields []genField, path string) (DataNodes, error) {
+	nodes := make(DataNodes, 0, len(fields))
+	for _, f := range fields {
+		fieldPath := f.name
+		if path != "" {
+			fieldPath = path + "." + f.name
+		}
+		node, err := buildNode(cfg, f, fieldPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fieldPath, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func buildNode(cfg *CrawlerConfig, f genField, path string) (DataNode, error) {
+	tag := parseCrawlTag(f.tag.Get("crawl"))
+	id := tag.ID
+	if jsonName, _, _ := strings.Cut(f.tag.Get("json"), ","); id == "" && jsonName != "-" {
+		id = jsonName
+	}
+	if id == "" {
+		id = lowerFirst(f.name)
+	}
+	node := ConfigNode{
+		ID:       id,
+		Selector: tag.Opts["selector"],
+		Label:    tag.Opts["label"],
+	}
+	if node.Label == "" {
+		node.Label = f.name
+	}
+
+	_, external := tag.Opts["external"]
+	if (f.shape == shapeForm || f.shape == shapeList) && !external {
+		sec := &DataSection{
+			ConfigNode:   node,
+			SectionType:  SectionForm,
+			FilterRender: tag.Opts["filter"],
+		}
+		if f.shape == shapeList {
+			sec.SectionType = SectionList
+		}
+		if st, ok := tag.Opts["section"]; ok {
+			sec.SectionType = SectionTypeString(st)
+			if sec.SectionType != SectionForm && sec.SectionType != SectionList {
+				return nil, fmt.Errorf("invalid section %q", st)
+			}
+		}
+		items, err := buildNodes(cfg, f.fields, path)
+		if err != nil {
+			return nil, err
+		}
+		sec.Items = items
+		return sec, nil
+	}
+
+	item := &ValueItem{
+		ConfigNode:  node,
+		ItemType:    ItemText,
+		ValueProper: tag.Opts["proper"],
+	}
+	if f.shape == shapeValues {
+		item.ItemType = ItemCheckBox
+	}
+	if it, ok := tag.Opts["type"]; ok {
+		item.ItemType = ItemTypeString(it)
+		valid := false
+		for _, allowed := range itemTypes {
+			valid = valid || it == allowed
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid type %q", it)
+		}
+	}
+	if r, ok := tag.Opts["render"]; ok {
+		if preset, isPreset := renderPresets[r]; isPreset {
+			item.ValueRender = preset
+		} else {
+			item.ValueRender = r
+		}
+	}
+	if external {
+		item.External = &ExternalItem{Config: ExternalConfig{Path: tag.Opts["external"]}}
+		if item.ValueProper == "" {
+			item.ValueProper = "href"
+		}
+	}
+	if item.ItemType == ItemDownload {
+		item.DownloadID = tag.Opts["download"]
+		if item.DownloadID == "" {
+			item.DownloadID = id
+		}
+		found := false
+		for _, dl := range cfg.DownloadSection {
+			found = found || dl.ID == item.DownloadID
+		}
+		if !found {
+			cfg.DownloadSection = append(cfg.DownloadSection, DownloadConfig{
+				ConfigNode:   ConfigNode{ID: item.DownloadID, Selector: node.Selector, Label: node.Label},
+				DownloadType: DownloadUrl,
+			})
+		}
+	}
+	return item, nil
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToLower(r[0])
+	}
+	return string(r)
+}
@@ -0,0 +1,148 @@
+package rpa
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type genRow struct {
+	Name  string   `crawl:"selector=.name"`
+	Price float64  `crawl:"selector=.price,type=text,render=number"`
+	Tags  []string `crawl:"selector=.tags input"`
+}
+
+type genPage struct {
+	Title string   `crawl:"title,selector=h1,label=Page title"`
+	Rows  []genRow `crawl:"selector=table tr"`
+	Owner struct {
+		Email string `json:"mail" crawl:"selector=.mail"`
+	} `crawl:"selector=.owner"`
+	Detail  genRow `crawl:"selector=a.detail,external=./detail.json"`
+	File    string `crawl:"selector=a.file,type=download,download=zip"`
+	Skipped string `crawl:"-"`
+}
+
+const genPageSource = `package model
+
+type genRow struct {
+	Name  string  ` + "`crawl:\"selector=.name\"`" + `
+	Price float64 ` + "`crawl:\"selector=.price,type=text,render=number\"`" + `
+	Tags  []string ` + "`crawl:\"selector=.tags input\"`" + `
+}
+
+type genPage struct {
+	Title  string   ` + "`crawl:\"title,selector=h1,label=Page title\"`" + `
+	Rows   []genRow ` + "`crawl:\"selector=table tr\"`" + `
+	Owner  struct {
+		Email string ` + "`json:\"mail\" crawl:\"selector=.mail\"`" + `
+	} ` + "`crawl:\"selector=.owner\"`" + `
+	Detail  genRow ` + "`crawl:\"selector=a.detail,external=./detail.json\"`" + `
+	File    string ` + "`crawl:\"selector=a.file,type=download,download=zip\"`" + `
+	Skipped string ` + "`crawl:\"-\"`" + `
+}
+`
+
+func TestGenerateConfig(t *testing.T) {
+	cfg, err := GenerateConfig(genPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.DataSection) != 5 {
+		t.Fatalf("expected 5 nodes, got %d", len(cfg.DataSection))
+	}
+
+	title := cfg.DataSection.Find("title").(*ValueItem)
+	if title.Selector != "h1" || title.Label != "Page title" || title.ItemType != ItemText {
+		t.Errorf("unexpected title %+v", title)
+	}
+
+	rows := cfg.DataSection.Find("rows").(*DataSection)
+	if rows.SectionType != SectionList || rows.Selector != "table tr" || len(rows.Items) != 3 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	price := rows.Items.Find("price").(*ValueItem)
+	if price.Selector != ".price" || price.ValueRender != renderPresets["number"] {
+		t.Errorf("unexpected price %+v", price)
+	}
+	if !strings.Contains(price.ValueRender, numberPattern.String()) {
+		t.Errorf("the number render doesn't read numbers like extractNumber: %s", price.ValueRender)
+	}
+	if tags := rows.Items.Find("tags").(*ValueItem); tags.ItemType != ItemCheckBox {
+		t.Errorf("unexpected tags %+v", tags)
+	}
+
+	owner := cfg.DataSection.Find("owner").(*DataSection)
+	if owner.SectionType != SectionForm || owner.Items.Find("mail") == nil {
+		t.Errorf("unexpected owner %+v", owner)
+	}
+
+	detail := cfg.DataSection.Find("detail").(*ValueItem)
+	if detail.External == nil || detail.External.Config.Path != "./detail.json" || detail.ValueProper != "href" {
+		t.Errorf("unexpected detail %+v", detail)
+	}
+
+	file := cfg.DataSection.Find("file").(*ValueItem)
+	if file.ItemType != ItemDownload || file.DownloadID != "zip" || len(cfg.DownloadSection) != 1 || cfg.DownloadSection[0].ID != "zip" {
+		t.Errorf("unexpected file %+v %+v", file, cfg.DownloadSection)
+	}
+
+	if err := ValidateConfig(cfg); err != nil {
+		t.Errorf("generated config is invalid: %v", err)
+	}
+
+	_, err = GenerateConfig(struct {
+		Price string `crawl:"type=price"`
+	}{})
+	if err == nil {
+		t.Errorf("expected an invalid type error")
+	}
+}
+
+func TestGenerateConfigFromFiles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "model.go")
+	if err := os.WriteFile(file, []byte(genPageSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fromSource, err := GenerateConfigFromFiles("genPage", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromType, err := GenerateConfig(genPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := json.Marshal(fromSource)
+	b, _ := json.Marshal(fromType)
+	var x, y interface{}
+	_ = json.Unmarshal(a, &x)
+	_ = json.Unmarshal(b, &y)
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("configs differ:\n%s\n%s", a, b)
+	}
+
+	if _, err = GenerateConfigFromFiles("missing", file); err == nil {
+		t.Errorf("expected a type not found error")
+	}
+}
+
+func TestGenerateDecodeRoundTrip(t *testing.T) {
+	res := &Result{Data: DictData{
+		"title": "Goods",
+		"rows": []interface{}{
+			map[string]interface{}{"name": "apple", "price": "12.5", "tags": []interface{}{"a"}},
+		},
+		"owner": map[string]interface{}{"mail": "a@b.c"},
+	}}
+	var page genPage
+	if err := res.Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Title != "Goods" || len(page.Rows) != 1 || page.Rows[0].Price != 12.5 || page.Owner.Email != "a@b.c" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
@@ -0,0 +1,78 @@
+package rpa
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-rod/rod"
+)
+
+// IncognitoOptions sets up an incognito context of the browser
+type IncognitoOptions struct {
+	// StateFile is a state file imported into the context, see Crawler.ImportState
+	StateFile string
+
+	// State selects the state imported from StateFile, and holds its key
+	State StateOptions
+
+	// DownloadTempDir is where the browser stages the downloading files of the context.
+	// It defaults to a dir of the context removed along with it, so the downloads of the contexts don't mix.
+	DownloadTempDir string
+}
+
+// Incognito returns a crawler running in a new incognito context of the browser,
+// its cookies and storage are isolated from the other contexts, and it has its own download dir.
+// It shares the config fetcher, the login and the secrets of c.
+//
+// Close the returned crawler to dispose of the context, the browser itself is left open.
+func (c *Crawler) Incognito(opts IncognitoOptions) (*Crawler, error) {
+	br, err := c.Browser.Incognito()
+	if err != nil {
+		return nil, err
+	}
+	w := &Crawler{
+		Browser:         br,
+		CfgFetcher:      c.CfgFetcher,
+		Login:           c.Login,
+		Secrets:         c.Secrets,
+		downloadTempDir: opts.DownloadTempDir,
+	}
+	if w.downloadTempDir == "" {
+		w.downloadTempDir = filepath.Join(defaultDownloadTempDir(), string(br.BrowserContextID))
+		w.ownsTempDir = true
+	}
+
+	if opts.StateFile != "" {
+		if err = w.ImportState(opts.StateFile, opts.State); err != nil {
+			_ = w.dispose()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// dispose closes the browser of c, or disposes of its context if it's incognito,
+// and removes the download dir of the context
+func (c *Crawler) dispose() error {
+	err := c.Browser.Close()
+	if c.ownsTempDir {
+		_ = os.RemoveAll(c.downloadTempDir)
+	}
+	return err
+}
+
+// crawlIncognito crawls the url in a new incognito context disposed when the crawl ends.
+// The page goes with the context, so it's closed and a nil page is returned.
+func (c *Crawler) crawlIncognito(ctx context.Context, url string, cfgOrFile interface{}, opts CrawlOptions) (*Result, *rod.Page, error) {
+	w, err := c.Incognito(*opts.Incognito)
+	if err != nil {
+		return nil, nil, canceledOr(ctx, err)
+	}
+	defer func() { _ = w.dispose() }()
+
+	opts.Incognito = nil
+	opts.CloseTab = true
+	res, _, err := w.CrawlUrlWithOptions(ctx, url, cfgOrFile, opts)
+	return res, nil, err
+}
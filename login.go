@@ -0,0 +1,287 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// LoginProfile describes how to log in to a site, as the login block of a config or as Crawler.Login.
+//
+// Before a page is crawled, the LoggedOut selector is checked. If it's visible, the cookies of the SessionFile
+// are restored and the page reloaded, and if it's still logged out, the Fields are filled in and submitted.
+// Once the Success selector shows, the cookies are saved to the SessionFile and the crawled url is opened again,
+// so later runs only log in when the session has expired.
+type LoginProfile struct {
+	// URL of the login page, the login form is expected on the crawled page if it's empty
+	URL string `json:"url,omitempty"`
+
+	// LoggedOut is the selector of an element visible only when logged out, such as the login form
+	LoggedOut string `json:"loggedOut"`
+
+	// Fields are the inputs of the login form
+	Fields []LoginField `json:"fields"`
+
+	// Submit is the selector of the submit button, Enter is pressed in the last field if it's empty
+	Submit string `json:"submit,omitempty"`
+
+	// Success is the selector of an element showing once logged in
+	Success string `json:"success"`
+
+	// Timeout is the seconds to wait for the form and for the Success selector, CrawlOptions.WaitTimeout by default
+	Timeout int `json:"timeout,omitempty"`
+
+	// SessionFile is the JSON file where the cookies of the session are saved and restored from, optional
+	SessionFile string `json:"sessionFile,omitempty"`
+}
+
+// LoginField is an input of a login form, its value is either Value, the environment variable Env,
+// or the secret Secret of Crawler.Secrets
+type LoginField struct {
+	Selector string `json:"selector"`
+	Value    string `json:"value,omitempty"`
+	Env      string `json:"env,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+// SecretProvider supplies the secret values of the login fields, such as a vault client
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// SecretFunc is a function as a SecretProvider
+type SecretFunc func(name string) (string, error)
+
+func (f SecretFunc) Secret(name string) (string, error) {
+	return f(name)
+}
+
+// LoginError is the failure to log in, the crawl stops with it
+type LoginError struct {
+	Cause error
+}
+
+func (e *LoginError) Error() string {
+	return "login failed: " + e.Cause.Error()
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Cause
+}
+
+// loginProfile returns the login of cfg, or the login of the crawler if cfg has none
+func (c *Crawler) loginProfile(cfg *CrawlerConfig) *LoginProfile {
+	if cfg.Login != nil {
+		return cfg.Login
+	}
+	return c.Login
+}
+
+// ensureLogin logs in if the page is logged out, then opens its url again
+func (c *Crawler) ensureLogin(ctx context.Context, page *rod.Page, lp *LoginProfile, opts CrawlOptions) error {
+	if err := page.WaitStable(opts.StableDuration); err != nil {
+		return err
+	}
+	if out, err := loggedOut(ctx, page, lp); err != nil || !out {
+		return err
+	}
+
+	// the tabs of a crawler share the cookies, one login is enough for all of them
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	info, err := page.Info()
+	if err != nil {
+		return err
+	}
+	crawlURL := info.URL
+
+	if restored, err := restoreSession(page, lp.SessionFile); err != nil {
+		return &LoginError{Cause: err}
+	} else if restored || c.loginCount > 0 {
+		// the session is restored, or another tab may have logged in meanwhile
+		if err = reloadStable(page, opts); err != nil {
+			return err
+		}
+		if out, err := loggedOut(ctx, page, lp); err != nil || !out {
+			return err
+		}
+	}
+
+	if err = c.login(ctx, page, lp, opts); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return &LoginError{Cause: err}
+	}
+	c.loginCount++
+
+	if lp.SessionFile != "" {
+		urls := []string{crawlURL}
+		if lp.URL != "" {
+			urls = append(urls, lp.URL)
+		}
+		if err = saveSession(page, lp.SessionFile, urls); err != nil {
+			return &LoginError{Cause: err}
+		}
+	}
+
+	if info, err = page.Info(); err != nil {
+		return err
+	}
+	if info.URL != crawlURL {
+		if err = page.Navigate(crawlURL); err != nil {
+			return err
+		}
+	}
+	return page.WaitStable(opts.StableDuration)
+}
+
+// loggedOut reports whether the LoggedOut selector is visible on the page.
+// A navigation of the page while it's checked is a LoginError, the cancellation of ctx is returned as is.
+func loggedOut(ctx context.Context, page *rod.Page, lp *LoginProfile) (bool, error) {
+	visible, err := elementVisibleContext(ctx, page, lp.LoggedOut)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, err
+		}
+		return false, &LoginError{Cause: fmt.Errorf("loggedOut: %w", err)}
+	}
+	return visible, nil
+}
+
+// login fills in and submits the login form, then waits for the success selector
+func (c *Crawler) login(ctx context.Context, page *rod.Page, lp *LoginProfile, opts CrawlOptions) error {
+	timeout := opts.WaitTimeout
+	if lp.Timeout > 0 {
+		timeout = time.Duration(lp.Timeout) * time.Second
+	}
+	if lp.URL != "" {
+		if err := page.Navigate(lp.URL); err != nil {
+			return err
+		}
+		if err := page.WaitStable(opts.StableDuration); err != nil {
+			return err
+		}
+	}
+
+	var last *rod.Element
+	for i, f := range lp.Fields {
+		val, err := c.fieldValue(f)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+		el, err := actionElem(ctx, page, f.Selector, timeout)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+		if err = el.SelectAllText(); err == nil {
+			err = el.Input(val)
+		}
+		if err != nil {
+			return fmt.Errorf("field %d: %w", i, err)
+		}
+		last = el
+	}
+
+	if lp.Submit != "" {
+		el, err := actionElem(ctx, page, lp.Submit, timeout)
+		if err != nil {
+			return fmt.Errorf("submit: %w", err)
+		}
+		if err = el.Click(proto.InputMouseButtonLeft, 1); err != nil {
+			return fmt.Errorf("submit: %w", err)
+		}
+	} else if last != nil {
+		if err := last.Type(input.Enter); err != nil {
+			return fmt.Errorf("submit: %w", err)
+		}
+	}
+
+	if err := waitElementTimeout(ctx, page, lp.Success, timeout, true); err != nil {
+		return fmt.Errorf("success: %w", err)
+	}
+	return nil
+}
+
+// fieldValue returns the value of the login field
+func (c *Crawler) fieldValue(f LoginField) (string, error) {
+	switch {
+	case f.Env != "":
+		val, ok := os.LookupEnv(f.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", f.Env)
+		}
+		return val, nil
+	case f.Secret != "":
+		if c.Secrets == nil {
+			return "", fmt.Errorf("no secret provider for secret %q", f.Secret)
+		}
+		return c.Secrets.Secret(f.Secret)
+	default:
+		return f.Value, nil
+	}
+}
+
+func reloadStable(page *rod.Page, opts CrawlOptions) error {
+	if err := page.Reload(); err != nil {
+		return err
+	}
+	return page.WaitStable(opts.StableDuration)
+}
+
+// restoreSession sets the cookies saved in file, it reports whether there were any
+func restoreSession(page *rod.Page, file string) (bool, error) {
+	if file == "" {
+		return false, nil
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var cookies []*proto.NetworkCookie
+	if err = json.Unmarshal(b, &cookies); err != nil {
+		return false, fmt.Errorf("invalid session file %s: %w", file, err)
+	}
+	if len(cookies) == 0 {
+		return false, nil
+	}
+	return true, page.SetCookies(cookieParams(cookies))
+}
+
+// cookieParams converts the cookies to set them again, the session cookies are set without an expiry
+func cookieParams(cookies []*proto.NetworkCookie) []*proto.NetworkCookieParam {
+	params := proto.CookiesToParams(cookies)
+	for i, ck := range cookies {
+		if ck.Session || ck.Expires <= 0 {
+			params[i].Expires = 0
+		}
+	}
+	return params
+}
+
+// saveSession saves the cookies of the urls to file, readable by the current user only
+func saveSession(page *rod.Page, file string, urls []string) error {
+	cookies, err := page.Cookies(urls)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0600)
+}
@@ -0,0 +1,81 @@
+package rpa_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rpa "github.com/rpdg/rod-helper"
+	"github.com/rpdg/rod-helper/rpatest"
+)
+
+func TestLogin(t *testing.T) {
+	h := rpatest.New(t, "testdata/site")
+	t.Setenv("RPATEST_USER", "alice")
+
+	var cfg rpa.CrawlerConfig
+	b, err := os.ReadFile(filepath.Join("testdata", "site", "login.json"))
+	if err == nil {
+		err = json.Unmarshal(b, &cfg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Login.SessionFile = filepath.Join(t.TempDir(), "session.json")
+
+	asked := 0
+	h.Crawler.Secrets = rpa.SecretFunc(func(name string) (string, error) {
+		asked++
+		if name != "password" {
+			return "", fmt.Errorf("unknown secret %q", name)
+		}
+		return "s3cret", nil
+	})
+
+	res, err := h.Crawl("login.html", &cfg, rpa.CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data["user"] != "alice" || asked != 1 {
+		t.Fatalf("expected to log in as alice once, got %v after %d logins", res.Data["user"], asked)
+	}
+	if _, err = os.Stat(cfg.Login.SessionFile); err != nil {
+		t.Fatalf("session not saved: %v", err)
+	}
+
+	// a new browser has no cookies, the saved session is restored instead of logging in again
+	h.Crawler.Browser = rpatest.Browser(t)
+	res, err = h.Crawl("login.html", &cfg, rpa.CrawlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Data["user"] != "alice" || asked != 1 {
+		t.Errorf("expected the restored session of alice, got %v after %d logins", res.Data["user"], asked)
+	}
+
+	// a session cookie is restored without an expiry
+	cfg.Login.SessionFile = filepath.Join(t.TempDir(), "session-cookie.json")
+	for i := 0; i < 2; i++ {
+		h.Crawler.Browser = rpatest.Browser(t)
+		res, err = h.Crawl("login.html?session", &cfg, rpa.CrawlOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Data["user"] != "alice" || asked != 2 {
+			t.Errorf("expected to log in as alice with a session cookie once, got %v after %d logins", res.Data["user"], asked)
+		}
+	}
+
+	h.Crawler.Browser = rpatest.Browser(t)
+	h.Crawler.Secrets = rpa.SecretFunc(func(string) (string, error) { return "wrong", nil })
+	cfg.Login.SessionFile = ""
+	cfg.Login.Timeout = 1
+	_, err = h.Crawl("login.html", &cfg, rpa.CrawlOptions{})
+	var loginErr *rpa.LoginError
+	if !errors.As(err, &loginErr) {
+		t.Errorf("expected a LoginError, got %v", err)
+	}
+}
@@ -0,0 +1,1275 @@
+package rpa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// nativeCrawler extracts a page like resource/crawler.js, but walks the config from Go with the rod element API.
+//
+// It works on the pages breaking crawler.js, such as the ones overriding Function, Array.from or querySelectorAll,
+// or having a CSP that blocks new Function. The renders of the config are still JavaScript,
+// each one is run as a function literal in the js context of its node, so they don't need eval either.
+type nativeCrawler struct {
+	cfg     *CrawlerConfig
+	cfgJson json.RawMessage
+	doc     *rod.Element
+	// the closed shadow roots reached by :deep, the :shadow() pseudos reach them through the DOM domain
+	roots  *shadowRoots
+	result *Result
+	// the externalSection of the result, keyed by connect
+	externals map[string]ExternalResult
+}
+
+// jsUndefined is an argument of a render passed as undefined instead of null
+type jsUndefined struct{}
+
+// jsError is an exception thrown by a render
+type jsError struct {
+	// message is err.message, nil if it's undefined
+	message *string
+	// text is String(err)
+	text string
+}
+
+func (e *jsError) Error() string {
+	if e.message != nil {
+		return *e.message
+	}
+	return e.text
+}
+
+// dataMessage is what crawler.js puts in the data for the failed render: err(${err.message})
+func (e *jsError) dataMessage() string {
+	if e.message != nil {
+		return *e.message
+	}
+	return "undefined"
+}
+
+// jsErrorOf turns the exception of a render that failed to compile into a jsError
+func jsErrorOf(e *rod.EvalError) *jsError {
+	text := e.Text
+	if e.Exception != nil && e.Exception.Description != "" {
+		text, _, _ = strings.Cut(e.Exception.Description, "\n")
+	}
+	msg := text
+	if _, after, found := strings.Cut(text, ": "); found {
+		msg = after
+	}
+	return &jsError{message: &msg, text: text}
+}
+
+// nativeRenderJS calls a render body with up to 3 params, this is window if self is null.
+// The args listed in undefs are undefined, the arg at nodeAt is the node, or all the nodes if multi.
+const nativeRenderJS = `function(self, args, undefs, nodeAt, multi, ...nodes) {
+	const render = function(%s) {
+%s
+	};
+	for (let i = 0; i < undefs.length; i++) args[undefs[i]] = undefined;
+	if (nodeAt >= 0) args[nodeAt] = multi ? nodes : (nodes.length ? nodes[0] : null);
+	try {
+		const res = render.call(self === null ? window : self, args[0], args[1], args[2]);
+		return res === undefined ? { undef: true } : { res };
+	} catch (err) {
+		return { fail: true, message: err == null ? undefined : err.message, text: String(err) };
+	}
+}`
+
+// nativeFilterJS keeps the rows passing a filterRender body
+const nativeFilterJS = `function(rows) {
+	const filter = function(val , i , arr) {
+%s
+	};
+	try {
+		const kept = [];
+		for (let i = 0; i < rows.length; i++) {
+			if (filter(rows[i], i, rows)) kept.push(rows[i]);
+		}
+		return { res: kept };
+	} catch (err) {
+		return { fail: true, message: err == null ? undefined : err.message, text: String(err) };
+	}
+}`
+
+// nativeDomJS calls a domRender body on the node, or all the nodes if multi
+const nativeDomJS = `function(multi, ...nodes) {
+	const render = function(dom) {
+%s
+	};
+	return render.call(window, multi ? nodes : (nodes.length ? nodes[0] : null));
+}`
+
+type nativeRenderResult struct {
+	Res     interface{} `json:"res"`
+	Undef   bool        `json:"undef"`
+	Fail    bool        `json:"fail"`
+	Message interface{} `json:"message"`
+	Text    string      `json:"text"`
+}
+
+func (r nativeRenderResult) err() *jsError {
+	e := &jsError{text: r.Text}
+	if msg, ok := r.Message.(string); ok {
+		e.message = &msg
+	}
+	return e
+}
+
+// crawlNative extracts the page with the native engine, the result is the same as evalCrawler's
+func crawlNative(page *rod.Page, cfg *CrawlerConfig, roots *shadowRoots) (*Result, error) {
+	cfgJson, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := documentOf(page)
+	if err != nil {
+		return nil, err
+	}
+	n := &nativeCrawler{
+		cfg:     cfg,
+		cfgJson: cfgJson,
+		doc:     doc,
+		roots:   roots,
+		result: &Result{
+			Data:      DictData{},
+			Downloads: map[string]DownloadResult{},
+			Errors:    []CrawlError{},
+		},
+		externals: make(map[string]ExternalResult),
+	}
+	return n.run()
+}
+
+func (n *nativeCrawler) run() (*Result, error) {
+	cfg := n.cfg
+	if cfg.DataSection != nil {
+		data, _, err := n.crawlByConfig(cfg.DataSection)
+		if err != nil {
+			return nil, err
+		}
+		n.result.Data = data
+	}
+
+	if sw := cfg.SwitchSection; sw != nil {
+		if err := n.crawlSwitch(sw); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DownloadRoot != "" {
+		root, err := n.formatDownloadRoot(cfg.DownloadRoot)
+		if err != nil {
+			return nil, err
+		}
+		n.result.DownloadRoot = root
+	}
+
+	for i := range cfg.DownloadSection {
+		if err := n.crawlDownloads(&cfg.DownloadSection[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(n.externals) > 0 {
+		n.result.ExternalSection = n.externals
+	}
+	return n.result, nil
+}
+
+// crawlByConfig crawls the nodes of a dataSection, it also returns the ids of the sections crawled as undefined
+func (n *nativeCrawler) crawlByConfig(nodes DataNodes) (DictData, []string, error) {
+	data := DictData{}
+	var undefs []string
+	for _, node := range nodes {
+		switch nd := node.(type) {
+		case *DataSection:
+			res, defined, err := n.crawlSection(nd, n.doc, "", "")
+			if err != nil {
+				return nil, nil, err
+			}
+			if defined {
+				data[nd.ID] = res
+			} else {
+				delete(data, nd.ID)
+				undefs = append(undefs, nd.ID)
+			}
+		case *ValueItem:
+			if nd.ItemType == "" {
+				continue
+			}
+			form, err := n.crawlForm("", n.doc, DataNodes{nd}, "", "")
+			if err != nil {
+				return nil, nil, err
+			}
+			data[nd.ID] = form[nd.ID]
+		}
+	}
+	return data, undefs, nil
+}
+
+func (n *nativeCrawler) crawlList(sectionID string, elems rod.Elements, items DataNodes, cncPath, dataPath string) ([]interface{}, error) {
+	rows := make([]interface{}, 0, len(elems))
+	for index, el := range elems {
+		row := make(map[string]interface{})
+		rowPath := joinDataPath(dataPath, strconv.Itoa(index))
+		for _, node := range items {
+			switch it := node.(type) {
+			case *ValueItem:
+				if it.ItemType == "" {
+					continue
+				}
+				if err := n.crawlValue(it, el, row, sectionID, cncPath, rowPath); err != nil {
+					return nil, err
+				}
+			case *DataSection:
+				res, defined, err := n.crawlSection(it, el, cncPath+"/"+sectionID, rowPath)
+				if err != nil {
+					return nil, err
+				}
+				setDefined(row, it.ID, res, defined)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (n *nativeCrawler) crawlForm(sectionID string, scope *rod.Element, items DataNodes, cncPath, dataPath string) (map[string]interface{}, error) {
+	obj := make(map[string]interface{})
+	for _, node := range items {
+		switch it := node.(type) {
+		case *ValueItem:
+			if it.ItemType == "" {
+				continue
+			}
+			if err := n.crawlValue(it, scope, obj, sectionID, cncPath, dataPath); err != nil {
+				return nil, err
+			}
+		case *DataSection:
+			res, defined, err := n.crawlSection(it, scope, cncPath+"/"+sectionID, dataPath)
+			if err != nil {
+				return nil, err
+			}
+			setDefined(obj, it.ID, res, defined)
+		}
+	}
+	return obj, nil
+}
+
+// crawlValue puts the value of the item into obj, the row of a list or the object of a form
+func (n *nativeCrawler) crawlValue(it *ValueItem, scope *rod.Element, obj map[string]interface{}, sectionID, cncPath, dataPath string) error {
+	itemPath := joinDataPath(dataPath, it.ID)
+	res, nodes, multi, err := n.crawlItem(it, scope, itemPath)
+	if err != nil {
+		return err
+	}
+	obj[it.ID] = res
+
+	if it.ValueRender != "" {
+		self, err := n.self(it, obj)
+		if err != nil {
+			return err
+		}
+		val, undef, err := n.render(scope, "val, node", it.ValueRender, self, []interface{}{res, nil}, 1, nodes, multi)
+		var jsErr *jsError
+		switch {
+		case errors.As(err, &jsErr):
+			n.report(itemPath, StageValueRender, it.Selector, jsErr)
+			obj[it.ID] = fmt.Sprintf("err(%s)", jsErr.dataMessage())
+		case err != nil:
+			return err
+		case !undef:
+			obj[it.ID] = val
+		}
+	}
+
+	if it.External != nil {
+		connect := cncPath + "/" + sectionID + "/" + it.ID
+		if _, ok := n.externals[connect]; !ok {
+			id := it.External.ID
+			if id == "" {
+				id = it.ID
+			}
+			n.externals[connect] = ExternalResult{Config: it.External.Config, Connect: connect, ID: id}
+		}
+	}
+	return nil
+}
+
+// crawlSection returns the data of the section, defined is false where crawler.js leaves it undefined
+func (n *nativeCrawler) crawlSection(sec *DataSection, parent *rod.Element, cncPath, dataPath string) (result interface{}, defined bool, err error) {
+	secPath := joinDataPath(dataPath, sec.ID)
+	nodes, multi := rod.Elements{parent}, false
+
+	switch sec.SectionType {
+	case SectionForm:
+		el, err := n.queryElem(sec.Selector, parent, sec.DomRender)
+		if err != nil {
+			return nil, false, err
+		}
+		nodes = nil
+		if el != nil {
+			nodes = rod.Elements{el}
+			obj, err := n.crawlForm(sec.ID, el, sec.Items, cncPath, secPath)
+			if err != nil {
+				return nil, false, err
+			}
+			result, defined = obj, true
+		}
+	case SectionList:
+		elems, err := n.queryElems(sec.Selector, parent, sec.DomRender)
+		if err != nil {
+			return nil, false, err
+		}
+		nodes, multi = elems, true
+		rows, err := n.crawlList(sec.ID, elems, sec.Items, cncPath, secPath)
+		if err != nil {
+			return nil, false, err
+		}
+		if sec.FilterRender != "" {
+			rows, err = n.filter(parent, sec.FilterRender, rows)
+			var jsErr *jsError
+			if errors.As(err, &jsErr) {
+				n.report(secPath, StageFilterRender, sec.Selector, jsErr)
+				var msg interface{}
+				if jsErr.message != nil {
+					msg = *jsErr.message
+				}
+				rows = []interface{}{msg}
+			} else if err != nil {
+				return nil, false, err
+			}
+		}
+		result, defined = rows, true
+	}
+
+	if sec.DataRender != "" {
+		self, err := n.self(sec, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		var val interface{} = result
+		if !defined {
+			val = jsUndefined{}
+		}
+		res, undef, err := n.render(parent, "val, node", sec.DataRender, self, []interface{}{val, nil}, 1, nodes, multi)
+		var jsErr *jsError
+		switch {
+		case errors.As(err, &jsErr):
+			n.report(secPath, StageDataRender, sec.Selector, jsErr)
+			result, defined = fmt.Sprintf("err(%s)", jsErr.dataMessage()), true
+		case err != nil:
+			return nil, false, err
+		case !undef:
+			result, defined = res, true
+		}
+	}
+	return result, defined, nil
+}
+
+// crawlItem returns the value of the item and the nodes it's crawled from, multi tells if it takes all of them
+func (n *nativeCrawler) crawlItem(it *ValueItem, scope *rod.Element, dataPath string) (result interface{}, nodes rod.Elements, multi bool, err error) {
+	switch it.ItemType {
+	case ItemRadioBox, ItemCheckBox:
+		nodes, err = n.queryElems(it.Selector, scope, it.DomRender)
+		if err != nil {
+			return nil, nil, true, err
+		}
+		if it.ItemType == ItemRadioBox {
+			result, err = checkedValue(nodes, it.ValueProper, "input[type=radio]", true)
+		} else {
+			result, err = checkedValue(nodes, it.ValueProper, "input[type=checkbox]", false)
+		}
+		return result, nodes, true, err
+
+	case ItemText, ItemTextBox, ItemDropBox, ItemDownload:
+		el, err := n.queryElem(it.Selector, scope, it.DomRender)
+		if err != nil || el == nil {
+			return nil, nil, false, err
+		}
+		nodes = rod.Elements{el}
+		switch it.ItemType {
+		case ItemText:
+			if it.ValueProper != "" {
+				result, err = attribute(el, it.ValueProper)
+			} else {
+				result, err = innerText(el)
+			}
+		case ItemTextBox:
+			var tag string
+			if tag, err = tagName(el); err == nil && (tag == "INPUT" || tag == "TEXTAREA") {
+				if it.ValueProper != "" {
+					result, err = attribute(el, it.ValueProper)
+				} else {
+					var value string
+					value, err = stringProperty(el, "value")
+					result = jsTrim(value)
+				}
+			}
+		case ItemDropBox:
+			result, err = selectedOption(el, strings.ToLower(it.ValueProper) == "value")
+		case ItemDownload:
+			if dn := n.downloadConfig(it.DownloadID); dn != nil {
+				var info DownloadFileInfo
+				info, err = n.crawlDownloadItem(dn, el, dataPath)
+				result = fileInfoData(info)
+			}
+		}
+		return result, nodes, false, err
+	}
+	return nil, nil, false, nil
+}
+
+// checkedValue returns the value of the first checked element if single, or the values of all the checked elements
+func checkedValue(elems rod.Elements, valueProper, inputSelector string, single bool) (interface{}, error) {
+	values := make([]interface{}, 0)
+	for _, el := range elems {
+		tag, err := tagName(el)
+		if err != nil {
+			return nil, err
+		}
+		input := el
+		if tag != "INPUT" {
+			if input, err = first(el, inputSelector, nil); err != nil {
+				return nil, err
+			}
+		}
+		if input == nil {
+			continue
+		}
+		checked, err := input.Property("checked")
+		if err != nil {
+			return nil, err
+		}
+		if !checked.Bool() {
+			continue
+		}
+
+		var value interface{}
+		switch {
+		case tag == "INPUT" && valueProper == "":
+			value, err = attribute(input, "value")
+		case tag == "INPUT" || valueProper != "":
+			value, err = attribute(el, valueProper)
+		default:
+			value, err = innerText(el)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if single {
+			return value, nil
+		}
+		values = append(values, value)
+	}
+	if single {
+		return nil, nil
+	}
+	return values, nil
+}
+
+// selectedOption returns the value or the text of the selected option of a select element
+func selectedOption(el *rod.Element, byValue bool) (interface{}, error) {
+	res, err := el.Eval(`function(byValue) {
+		const opt = this.options[this.selectedIndex];
+		return byValue ? opt.value : opt.text;
+	}`, byValue)
+	if err != nil {
+		return nil, err
+	}
+	return res.Value.Str(), nil
+}
+
+func (n *nativeCrawler) downloadConfig(id string) *DownloadConfig {
+	for i := range n.cfg.DownloadSection {
+		if n.cfg.DownloadSection[i].ID == id {
+			return &n.cfg.DownloadSection[i]
+		}
+	}
+	return nil
+}
+
+var fileNameSanitizer = regexp.MustCompile(`[\\/:*?"<>|\r\n\t]`)
+
+func (n *nativeCrawler) crawlDownloadItem(dn *DownloadConfig, el *rod.Element, dataPath string) (DownloadFileInfo, error) {
+	var info DownloadFileInfo
+	visible, err := hasHeight(el)
+	if err != nil || !visible {
+		return info, err
+	}
+
+	var name interface{}
+	if dn.NameProper != "" {
+		name, err = attribute(el, dn.NameProper)
+	} else {
+		var text string
+		text, err = stringProperty(el, "text")
+		name = jsTrim(text)
+	}
+	if err != nil {
+		return info, err
+	}
+
+	if dn.NameRender != "" && dn.NameRender != "auto" {
+		res, undef, err := n.downloadRender(dn, el, "name, node", dn.NameRender, name)
+		var jsErr *jsError
+		switch {
+		case errors.As(err, &jsErr):
+			n.report(dataPath, StageNameRender, dn.Selector, jsErr)
+			if jsErr.message != nil {
+				info.Error = *jsErr.message
+			}
+		case err != nil:
+			return info, err
+		case !undef && jsTruthy(res):
+			name = jsString(res)
+		}
+	}
+
+	fileName := jsString(name)
+	if dn.DownloadType == PrintToPDF && info.Error == "" {
+		fileName += ".pdf"
+	}
+	info.Name = fileNameSanitizer.ReplaceAllString(fileName, "")
+
+	if dn.DownloadType == DownloadUrl || dn.DownloadType == PrintToPDF {
+		link := ""
+		if dn.LinkProper != "" {
+			attr, err := el.Attribute(dn.LinkProper)
+			if err != nil {
+				return info, err
+			}
+			if attr != nil {
+				link = *attr
+			}
+		} else if tag, err := tagName(el); err != nil {
+			return info, err
+		} else if tag == "A" {
+			if link, err = stringProperty(el, "href"); err != nil {
+				return info, err
+			}
+		}
+
+		if dn.LinkRender != "" {
+			res, undef, err := n.downloadRender(dn, el, "link, node", dn.LinkRender, link)
+			var jsErr *jsError
+			switch {
+			case errors.As(err, &jsErr):
+				n.report(dataPath, StageLinkRender, dn.Selector, jsErr)
+				if jsErr.message != nil {
+					info.Error = *jsErr.message
+				}
+			case err != nil:
+				return info, err
+			case !undef && jsTruthy(res):
+				link = jsString(res)
+			}
+		}
+		info.Url = link
+	}
+	return info, nil
+}
+
+func (n *nativeCrawler) downloadRender(dn *DownloadConfig, el *rod.Element, params, body string, val interface{}) (interface{}, bool, error) {
+	self, err := n.self(dn, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	return n.render(el, params, body, self, []interface{}{val, nil}, 1, rod.Elements{el}, false)
+}
+
+// crawlDownloads crawls the files of a downloadSection item and inserts them into the data if insertTo is set
+func (n *nativeCrawler) crawlDownloads(dn *DownloadConfig) error {
+	elems, err := n.queryElems(dn.Selector, n.doc, dn.DomRender)
+	if err != nil {
+		return err
+	}
+	files := make([]DownloadFileInfo, 0)
+	n.result.Downloads[dn.ID] = DownloadResult{Label: dn.Label, Files: files}
+	for _, el := range elems {
+		visible, err := hasHeight(el)
+		if err != nil {
+			return err
+		}
+		if !visible {
+			continue
+		}
+		info, err := n.crawlDownloadItem(dn, el, joinDataPath("downloads", dn.ID, "files", strconv.Itoa(len(files))))
+		if err != nil {
+			return err
+		}
+		files = append(files, info)
+		n.result.Downloads[dn.ID] = DownloadResult{Label: dn.Label, Files: files}
+		if dn.InsertTo != "" {
+			n.insertFile(dn, info)
+		}
+	}
+	return nil
+}
+
+// insertFile appends the file to the files of the insertTo node of the data,
+// the node is replaced by a files holder if it doesn't have files yet.
+func (n *nativeCrawler) insertFile(dn *DownloadConfig, info DownloadFileInfo) {
+	pathArr := strings.Split(dn.InsertTo, ".")
+	target := map[string]interface{}(n.result.Data)
+	for _, k := range pathArr[:len(pathArr)-1] {
+		v, ok := target[k]
+		if !ok {
+			sub := make(map[string]interface{})
+			target[k] = sub
+			target = sub
+			continue
+		}
+		if target, ok = v.(map[string]interface{}); !ok {
+			return
+		}
+	}
+
+	prop := pathArr[len(pathArr)-1]
+	cur, ok := target[prop]
+	if !ok {
+		return
+	}
+	holder, _ := cur.(map[string]interface{})
+	files, ok := holder["files"].([]interface{})
+	if !ok {
+		holder = map[string]interface{}{"downloadId": dn.ID}
+		if dn.Label != "" {
+			holder["label"] = dn.Label
+		}
+		files = make([]interface{}, 0)
+	}
+	holder["files"] = append(files, fileInfoData(info))
+	target[prop] = holder
+}
+
+func (n *nativeCrawler) crawlSwitch(sw *SwitchSection) error {
+	self, err := n.self(sw, nil)
+	if err != nil {
+		return err
+	}
+	res, undef, err := n.render(n.doc, "data, config", sw.SwitchRender, self, []interface{}{n.result.Data, n.cfgJson}, -1, nil, false)
+	var jsErr *jsError
+	if errors.As(err, &jsErr) {
+		n.report("switchSection", StageSwitchRender, "", jsErr)
+		return nil
+	} else if err != nil || undef {
+		return err
+	}
+
+	for i, c := range sw.Cases {
+		if !caseMatches(jsonValue(c.Case), res) {
+			continue
+		}
+		n.result.matchedCase = &i
+		data, undefs, err := n.crawlByConfig(c.DataSection)
+		if err != nil {
+			return err
+		}
+		assignDeep(n.result.Data, data)
+		for _, id := range undefs {
+			delete(n.result.Data, id)
+		}
+		break
+	}
+	return nil
+}
+
+// caseMatches is c.case === swRes || (c.case instanceof Array && c.case.indexOf(swRes) > -1) of crawler.js
+func caseMatches(c, v interface{}) bool {
+	if jsStrictEqual(c, v) {
+		return true
+	}
+	if list, ok := c.([]interface{}); ok {
+		for _, x := range list {
+			if jsStrictEqual(x, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsStrictEqual(a, b interface{}) bool {
+	switch a.(type) {
+	case nil:
+		return b == nil
+	case string, float64, bool:
+		return a == b
+	}
+	return false
+}
+
+// assignDeep merges source into target like the assignDeep of crawler.js,
+// objects and arrays are merged recursively, arrays by index.
+func assignDeep(target, source map[string]interface{}) {
+	for k, v := range source {
+		target[k] = mergeDeep(target[k], v)
+	}
+}
+
+func mergeDeep(target, source interface{}) interface{} {
+	switch src := source.(type) {
+	case map[string]interface{}:
+		dst, ok := target.(map[string]interface{})
+		if !ok {
+			dst = make(map[string]interface{}, len(src))
+		}
+		for k, v := range src {
+			dst[k] = mergeDeep(dst[k], v)
+		}
+		return dst
+	case []interface{}:
+		dst, ok := target.([]interface{})
+		if !ok {
+			dst = make([]interface{}, 0, len(src))
+		}
+		for i, v := range src {
+			if i < len(dst) {
+				dst[i] = mergeDeep(dst[i], v)
+			} else {
+				dst = append(dst, mergeDeep(nil, v))
+			}
+		}
+		return dst
+	}
+	return source
+}
+
+var downloadRootPattern = regexp.MustCompile(`\$\{(\w[\w.]*)\}`)
+
+// formatDownloadRoot replaces the ${key.path} of the template with the values of the result,
+// an undefined value is replaced by null like crawler.js.
+func (n *nativeCrawler) formatDownloadRoot(template string) (string, error) {
+	root := jsonValue(n.resultState())
+	var sb strings.Builder
+	last := 0
+	for _, m := range downloadRootPattern.FindAllStringSubmatchIndex(template, -1) {
+		if m[1] < len(template) && template[m[1]] == '}' {
+			continue
+		}
+		val, defined, err := lookupJsPath(root, template[m[2]:m[3]])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(template[last:m[0]])
+		if defined {
+			sb.WriteString(jsString(val))
+		} else {
+			sb.WriteString("null")
+		}
+		last = m[1]
+	}
+	sb.WriteString(template[last:])
+	return sb.String(), nil
+}
+
+// lookupJsPath is key.split('.').reduce((obj, k) => obj[k], root) of crawler.js
+func lookupJsPath(root interface{}, key string) (interface{}, bool, error) {
+	cur, defined := root, true
+	for _, k := range strings.Split(key, ".") {
+		if !defined || cur == nil {
+			return nil, false, fmt.Errorf("downloadRoot: cannot read properties of %s (reading '%s')", jsString(cur), k)
+		}
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			cur, defined = v[k]
+		case []interface{}:
+			if k == "length" {
+				cur = float64(len(v))
+			} else if i, err := strconv.Atoi(k); err == nil && i >= 0 && i < len(v) && strconv.Itoa(i) == k {
+				cur = v[i]
+			} else {
+				cur, defined = nil, false
+			}
+		case string:
+			if k == "length" {
+				cur = float64(len(utf16.Encode([]rune(v))))
+			} else {
+				cur, defined = nil, false
+			}
+		default:
+			cur, defined = nil, false
+		}
+	}
+	return cur, defined, nil
+}
+
+// queryElem returns the element of the selector under parent, or parent itself if the selector is empty, nil if not found
+func (n *nativeCrawler) queryElem(selector string, parent *rod.Element, domRender string) (*rod.Element, error) {
+	node := parent
+	if selector != "" {
+		doc, sel, err := replacePseudoNative(selector, parent, n.roots)
+		if err != nil {
+			return nil, err
+		}
+		if node, err = first(doc, sel, n.roots); err != nil {
+			return nil, err
+		}
+	}
+	if domRender == "" {
+		return node, nil
+	}
+
+	var nodes rod.Elements
+	if node != nil {
+		nodes = rod.Elements{node}
+	}
+	where, args := renderArgs(parent, nodes, false)
+	el, err := where.ElementByJS(rod.Eval(fmt.Sprintf(nativeDomJS, domRender), args...))
+	var notFound *rod.ElementNotFoundError
+	var notElement *rod.ExpectElementError
+	if errors.As(err, &notFound) || errors.As(err, &notElement) {
+		return nil, nil
+	}
+	return el, err
+}
+
+// queryElems returns the elements of the selector under parent, or parent itself if the selector is empty
+func (n *nativeCrawler) queryElems(selector string, parent *rod.Element, domRender string) (rod.Elements, error) {
+	nodes := rod.Elements{parent}
+	if selector != "" {
+		doc, sel, err := replacePseudoNative(selector, parent, n.roots)
+		if err != nil {
+			return nil, err
+		}
+		if nodes, err = queryDom(doc, sel, true, n.roots); err != nil {
+			return nil, err
+		}
+	}
+	if domRender == "" {
+		return nodes, nil
+	}
+
+	where, args := renderArgs(parent, nodes, true)
+	return where.ElementsByJS(rod.Eval(fmt.Sprintf(nativeDomJS, domRender), args...))
+}
+
+// xpathPrefix marks a selector as an xpath expression
+const xpathPrefix = "xpath:"
+
+// nativeXPathJS returns the elements of the xpath expression under this, only the first one unless all
+const nativeXPathJS = `function (expr, all) {
+	const doc = this.ownerDocument || this;
+	const res = doc.evaluate(expr, this, null, 7, null);
+	const nodes = [];
+	for (let i = 0; i < res.snapshotLength && (all || nodes.length === 0); i++) {
+		const node = res.snapshotItem(i);
+		if (node.nodeType === 1) nodes.push(node);
+	}
+	return nodes;
+}`
+
+// stepPseudoPattern matches the :contains(), :matches(), :label(), :next, :parent and :deep pseudos
+var stepPseudoPattern = regexp.MustCompile(`:(contains|matches|label)\(|:(next|parent|deep)([^\w-]|$)`)
+
+// nativeStepsJS returns the elements of a selector with the step pseudos under this, only the first one unless all
+var nativeStepsJS = fmt.Sprintf(`function (selector, all, roots) {
+	%s
+	closedRoots = roots;
+	const nodes = selectSteps(this, selector);
+	return all ? nodes : nodes.slice(0, 1);
+}`, commonJSCode)
+
+var pseudoPattern = regexp.MustCompile(`^:(frame|shadow)\(`)
+
+// leadingPseudo splits the leading :frame() or :shadow() off the selector, it returns the pseudo, its argument
+// and the selector following it. The pseudo is empty if the selector doesn't start with one.
+func leadingPseudo(selector string) (pseudo, arg, rest string, err error) {
+	m := pseudoPattern.FindStringSubmatch(selector)
+	if m == nil {
+		return "", "", selector, nil
+	}
+	arg, end, err := readPseudoArg(selector[len(m[0]):])
+	if err != nil {
+		return "", "", "", err
+	}
+	return m[1], arg, strings.TrimSpace(selector[len(m[0])+end:]), nil
+}
+
+// readPseudoArg reads the argument of a pseudo from the text following its '(', like readPseudoArg of crawler.js.
+// It's either quoted or has balanced brackets, so an xpath such as //iframe[contains(@src,"pay")] is read whole.
+// end is the length of the argument with its ')'.
+func readPseudoArg(s string) (arg string, end int, err error) {
+	if arg, end, ok := quotedPseudoArg(s); ok {
+		return arg, end, nil
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return strings.TrimSpace(s[:i]), i + 1, nil
+			}
+			depth--
+		}
+	}
+	return "", 0, fmt.Errorf("unclosed pseudo argument: %s", s)
+}
+
+// quotedPseudoArg reads a quoted pseudo argument followed by its ')', ok is false if s isn't one
+func quotedPseudoArg(s string) (arg string, end int, ok bool) {
+	t := strings.TrimLeft(s, " \t\n\r\f")
+	if t == "" || (t[0] != '"' && t[0] != '\'') {
+		return "", 0, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(t); i++ {
+		switch t[i] {
+		case '\\':
+			if i+1 < len(t) {
+				i++
+			}
+			b.WriteByte(t[i])
+		case t[0]:
+			rest := strings.TrimLeft(t[i+1:], " \t\n\r\f")
+			if !strings.HasPrefix(rest, ")") {
+				return "", 0, false
+			}
+			return b.String(), len(s) - len(rest) + 1, true
+		default:
+			b.WriteByte(t[i])
+		}
+	}
+	return "", 0, false
+}
+
+// replacePseudoNative resolves the leading :frame() and :shadow() of the selector,
+// it returns the document or the shadow root to query the rest of the selector in.
+func replacePseudoNative(selector string, parent *rod.Element, roots *shadowRoots) (*rod.Element, string, error) {
+	pseudo, arg, rest, err := leadingPseudo(selector)
+	if err != nil {
+		return nil, "", err
+	}
+	if pseudo == "" {
+		return parent, selector, nil
+	}
+	host, err := first(parent, arg, roots)
+	if err != nil {
+		return nil, "", err
+	}
+	if host == nil {
+		return nil, "", fmt.Errorf(":%s(%s) not found", pseudo, arg)
+	}
+
+	var doc *rod.Element
+	if pseudo == "frame" {
+		frame, err := framePage(host)
+		if err != nil {
+			return nil, "", err
+		}
+		doc, err = documentOf(frame)
+		if err != nil {
+			return nil, "", err
+		}
+	} else if doc, err = host.ShadowRoot(); err != nil {
+		return nil, "", err
+	}
+	return replacePseudoNative(rest, doc, roots)
+}
+
+// documentOf returns the document of the page or the frame
+func documentOf(page *rod.Page) (*rod.Element, error) {
+	// the DOM domain requires the document to be requested before querying its nodes
+	if _, err := (proto.DOMGetDocument{}).Call(page); err != nil {
+		return nil, err
+	}
+	return page.Sleeper(rod.NotFoundSleeper).ElementByJS(rod.Eval(`() => document`))
+}
+
+// first returns the first element of the selector under scope, nil if there is none
+func first(scope *rod.Element, selector string, roots *shadowRoots) (*rod.Element, error) {
+	elems, err := queryDom(scope, selector, false, roots)
+	if err != nil || len(elems) == 0 {
+		return nil, err
+	}
+	return elems[0], nil
+}
+
+// queryDom runs querySelector, or querySelectorAll if all, on scope through the DOM domain,
+// so it keeps working on the pages overriding them.
+// An xpath expression prefixed with "xpath:" and a selector with the step pseudos are evaluated in the page instead,
+// the DOM domain has no relative xpath query nor text matching.
+func queryDom(scope *rod.Element, selector string, all bool, roots *shadowRoots) (rod.Elements, error) {
+	if expr, ok := strings.CutPrefix(selector, xpathPrefix); ok {
+		return scope.ElementsByJS(rod.Eval(nativeXPathJS, expr, all))
+	}
+	if stepPseudoPattern.MatchString(selector) {
+		rootsArg, err := roots.arg(scope.Page())
+		if err != nil {
+			return nil, err
+		}
+		return scope.ElementsByJS(rod.Eval(nativeStepsJS, selector, all, rootsArg))
+	}
+
+	page := scope.Page()
+	node, err := proto.DOMRequestNode{ObjectID: scope.Object.ObjectID}.Call(page)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []proto.DOMNodeID
+	if all {
+		res, err := proto.DOMQuerySelectorAll{NodeID: node.NodeID, Selector: selector}.Call(page)
+		if err != nil {
+			return nil, err
+		}
+		ids = res.NodeIDs
+	} else {
+		res, err := proto.DOMQuerySelector{NodeID: node.NodeID, Selector: selector}.Call(page)
+		if err != nil {
+			return nil, err
+		}
+		if res.NodeID != 0 {
+			ids = append(ids, res.NodeID)
+		}
+	}
+
+	elems := make(rod.Elements, 0, len(ids))
+	for _, id := range ids {
+		obj, err := proto.DOMResolveNode{NodeID: id}.Call(page)
+		if err != nil {
+			return nil, err
+		}
+		el, err := page.ElementFromObject(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, el)
+	}
+	return elems, nil
+}
+
+// renderArgs returns the element to eval a render in and the args of the nodes,
+// the render is run in the js context of the nodes, or of scope if there is none.
+func renderArgs(scope *rod.Element, nodes rod.Elements, multi bool) (*rod.Element, []interface{}) {
+	args := []interface{}{multi}
+	for _, el := range nodes {
+		args = append(args, el.Object)
+	}
+	if len(nodes) > 0 {
+		return nodes[0], args
+	}
+	return scope, args
+}
+
+// render calls a render body with the params, self is the this of the render, nil for window.
+// A jsError is returned if the render throws.
+func (n *nativeCrawler) render(scope *rod.Element, params, body string, self interface{}, args []interface{}, nodeAt int, nodes rod.Elements, multi bool) (interface{}, bool, error) {
+	undefs := make([]int, 0)
+	values := make([]interface{}, len(args))
+	for i, a := range args {
+		if _, ok := a.(jsUndefined); ok {
+			undefs = append(undefs, i)
+		} else {
+			values[i] = a
+		}
+	}
+	where, nodeArgs := renderArgs(scope, nodes, multi)
+	jsArgs := append([]interface{}{self, values, undefs, nodeAt}, nodeArgs...)
+	return evalRender(where, fmt.Sprintf(nativeRenderJS, params, body), jsArgs...)
+}
+
+// filter keeps the rows passing the filterRender body
+func (n *nativeCrawler) filter(scope *rod.Element, body string, rows []interface{}) ([]interface{}, error) {
+	res, _, err := evalRender(scope, fmt.Sprintf(nativeFilterJS, body), rows)
+	if err != nil {
+		return nil, err
+	}
+	kept, _ := res.([]interface{})
+	return kept, nil
+}
+
+func evalRender(where *rod.Element, js string, args ...interface{}) (interface{}, bool, error) {
+	res, err := where.Evaluate(rod.Eval(js, args...))
+	var evalErr *rod.EvalError
+	if errors.As(err, &evalErr) {
+		return nil, false, jsErrorOf(evalErr)
+	} else if err != nil {
+		return nil, false, err
+	}
+	var out nativeRenderResult
+	if err = res.Value.Unmarshal(&out); err != nil {
+		return nil, false, err
+	}
+	if out.Fail {
+		return nil, false, out.err()
+	}
+	return out.Res, out.Undef, nil
+}
+
+// self returns the this of a render, the fields of the config node with the ctx of crawler.js
+func (n *nativeCrawler) self(node interface{}, curSectionResult map[string]interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(node)
+	if err != nil {
+		return nil, err
+	}
+	var self map[string]interface{}
+	if err = json.Unmarshal(b, &self); err != nil {
+		return nil, err
+	}
+	ctx := map[string]interface{}{
+		"__config__": n.cfgJson,
+		"__result__": n.resultState(),
+	}
+	if curSectionResult != nil {
+		ctx["curSectionResult"] = curSectionResult
+	}
+	self["ctx"] = ctx
+	return self, nil
+}
+
+// resultState is the __result__ of crawler.js so far
+func (n *nativeCrawler) resultState() map[string]interface{} {
+	state := map[string]interface{}{
+		"data":      n.result.Data,
+		"downloads": n.result.Downloads,
+		"errors":    n.result.Errors,
+	}
+	if n.result.DownloadRoot != "" {
+		state["downloadRoot"] = n.result.DownloadRoot
+	}
+	return state
+}
+
+func (n *nativeCrawler) report(path, stage, selector string, err *jsError) {
+	n.result.Errors = append(n.result.Errors, CrawlError{Path: path, Stage: stage, Selector: selector, Message: err.Error()})
+}
+
+func setDefined(obj map[string]interface{}, key string, val interface{}, defined bool) {
+	if defined {
+		obj[key] = val
+	} else {
+		delete(obj, key)
+	}
+}
+
+func fileInfoData(info DownloadFileInfo) map[string]interface{} {
+	return map[string]interface{}{"name": info.Name, "url": info.Url, "error": info.Error}
+}
+
+// jsonValue returns v as decoded from its JSON, such as map[string]interface{} for a struct and float64 for a number
+func jsonValue(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var val interface{}
+	_ = json.Unmarshal(b, &val)
+	return val
+}
+
+func attribute(el *rod.Element, name string) (interface{}, error) {
+	attr, err := el.Attribute(name)
+	if err != nil || attr == nil {
+		return nil, err
+	}
+	return *attr, nil
+}
+
+func stringProperty(el *rod.Element, name string) (string, error) {
+	prop, err := el.Property(name)
+	if err != nil {
+		return "", err
+	}
+	return prop.Str(), nil
+}
+
+func tagName(el *rod.Element) (string, error) {
+	return stringProperty(el, "tagName")
+}
+
+func innerText(el *rod.Element) (string, error) {
+	text, err := stringProperty(el, "innerText")
+	return jsTrim(text), err
+}
+
+func hasHeight(el *rod.Element) (bool, error) {
+	res, err := el.Eval(`function() { return this.getBoundingClientRect().height > 0 }`)
+	if err != nil {
+		return false, err
+	}
+	return res.Value.Bool(), nil
+}
+
+// jsTrim trims the white spaces of String.prototype.trim
+func jsTrim(s string) string {
+	return strings.TrimFunc(s, func(r rune) bool {
+		return r == '\uFEFF' || (r != '\u0085' && unicode.IsSpace(r))
+	})
+}
+
+// jsTruthy tells if a JSON value is truthy in JavaScript
+func jsTruthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0 && !math.IsNaN(x)
+	case string:
+		return x != ""
+	}
+	return true
+}
+
+// jsString is String(v) of a JSON value in JavaScript
+func jsString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return x
+	case bool:
+		return strconv.FormatBool(x)
+	case float64:
+		return jsNumber(x)
+	case []interface{}:
+		parts := make([]string, len(x))
+		for i, e := range x {
+			if e != nil {
+				parts[i] = jsString(e)
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	return "[object Object]"
+}
+
+// jsNumber formats a number like Number.prototype.toString
+func jsNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e21 || abs < 1e-6 {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		mantissa, exp, _ := strings.Cut(s, "e")
+		sign := exp[:1]
+		exp = strings.TrimLeft(exp[1:], "0")
+		return mantissa + "e" + sign + exp
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
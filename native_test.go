@@ -0,0 +1,211 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// hostileScript breaks crawler.js the way some sites do
+const hostileScript = `<script>
+	window.Function = function () { throw new Error('Function is disabled'); };
+	Array.from = function () { throw new Error('Array.from is disabled'); };
+	Document.prototype.querySelectorAll = Element.prototype.querySelectorAll = function () {
+		throw new Error('querySelectorAll is disabled');
+	};
+</script>`
+
+// engineFixtureServer serves testdata/engine, the pages are made hostile by the hostile query
+func engineFixtureServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := os.ReadFile(filepath.Join("testdata", "engine", filepath.Base(r.URL.Path)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Has("hostile") {
+			w.Header().Set("Content-Security-Policy", "script-src 'self' 'unsafe-inline'")
+			b = []byte(strings.Replace(string(b), "<head>", "<head>"+hostileScript, 1))
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func engineBrowser(t *testing.T) *rod.Browser {
+	br, err := ConnectChromiumBrowser(true, true)
+	if err != nil {
+		t.Skipf("no browser: %v", err)
+	}
+	t.Cleanup(func() { _ = br.Close() })
+	return br
+}
+
+func crawlWithEngine(t *testing.T, c *Crawler, url, cfgFile string, engine Engine) (interface{}, error) {
+	cfg, _, err := c.fetchCfg(context.Background(), cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := c.Browser.Page(proto.TargetCreateTarget{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.crawlPage(context.Background(), page, cfg, CrawlOptions{CloseTab: true, Engine: engine}.withDefaults())
+	return jsonValue(res), err
+}
+
+// TestEngineConformance crawls each fixture of testdata/engine with both engines, the results must be the same
+func TestEngineConformance(t *testing.T) {
+	c := &Crawler{Browser: engineBrowser(t)}
+	srv := engineFixtureServer(t)
+
+	cfgFiles, _ := filepath.Glob(filepath.Join("testdata", "engine", "*.json"))
+	if len(cfgFiles) == 0 {
+		t.Fatal("no fixtures")
+	}
+	for _, cfgFile := range cfgFiles {
+		name := strings.TrimSuffix(filepath.Base(cfgFile), ".json")
+		t.Run(name, func(t *testing.T) {
+			url := srv.URL + "/" + name + ".html"
+			jsRes, err := crawlWithEngine(t, c, url, cfgFile, EngineJS)
+			if err != nil {
+				t.Fatalf("js engine: %v", err)
+			}
+			nativeRes, err := crawlWithEngine(t, c, url, cfgFile, EngineNative)
+			if err != nil {
+				t.Fatalf("native engine: %v", err)
+			}
+			if !reflect.DeepEqual(jsRes, nativeRes) {
+				a, _ := json.MarshalIndent(jsRes, "", "  ")
+				b, _ := json.MarshalIndent(nativeRes, "", "  ")
+				t.Errorf("results differ\njs:\n%s\nnative:\n%s", a, b)
+			}
+
+			// the native engine doesn't depend on the globals of the page
+			hostileRes, err := crawlWithEngine(t, c, url+"?hostile", cfgFile, EngineNative)
+			if err != nil {
+				t.Fatalf("native engine on the hostile page: %v", err)
+			}
+			if !reflect.DeepEqual(jsRes, hostileRes) {
+				b, _ := json.MarshalIndent(hostileRes, "", "  ")
+				t.Errorf("hostile page results differ:\n%s", b)
+			}
+		})
+	}
+}
+
+func TestEngineFixturesValid(t *testing.T) {
+	cfgFiles, _ := filepath.Glob(filepath.Join("testdata", "engine", "*.json"))
+	c := &Crawler{}
+	for _, cfgFile := range cfgFiles {
+		if _, _, err := c.fetchCfg(context.Background(), cfgFile); err != nil {
+			t.Errorf("%s: %v", cfgFile, err)
+		}
+	}
+}
+
+func Test_assignDeep(t *testing.T) {
+	target := map[string]interface{}{
+		"title": "a",
+		"form":  map[string]interface{}{"x": "1"},
+		"list":  []interface{}{map[string]interface{}{"a": "1"}},
+	}
+	assignDeep(target, map[string]interface{}{
+		"title": "b",
+		"form":  map[string]interface{}{"y": "2"},
+		"list":  []interface{}{map[string]interface{}{"b": "2"}, "c"},
+	})
+	expected := map[string]interface{}{
+		"title": "b",
+		"form":  map[string]interface{}{"x": "1", "y": "2"},
+		"list":  []interface{}{map[string]interface{}{"a": "1", "b": "2"}, "c"},
+	}
+	if !reflect.DeepEqual(target, expected) {
+		t.Errorf("unexpected %v", target)
+	}
+}
+
+func Test_formatDownloadRoot(t *testing.T) {
+	n := &nativeCrawler{result: &Result{Data: DictData{
+		"title": "Goods",
+		"page":  float64(2),
+		"tags":  []interface{}{"a", "b"},
+	}}}
+	cases := []struct {
+		template, expected string
+	}{
+		{"/tmp/${data.title}/p${data.page}", "/tmp/Goods/p2"},
+		{"${data.none}-${data.tags}-${data.tags.length}", "null-a,b-2"},
+		{"${data.title}}", "${data.title}}"},
+	}
+	for _, c := range cases {
+		if got, err := n.formatDownloadRoot(c.template); err != nil || got != c.expected {
+			t.Errorf("formatDownloadRoot(%q) = %q, %v, expected %q", c.template, got, err, c.expected)
+		}
+	}
+	if _, err := n.formatDownloadRoot("${data.none.x}"); err == nil {
+		t.Errorf("expected an error reading a property of undefined")
+	}
+}
+
+func Test_jsValues(t *testing.T) {
+	strs := []struct {
+		val      interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{float64(12), "12"},
+		{1.5, "1.5"},
+		{1e21, "1e+21"},
+		{1.5e-7, "1.5e-7"},
+		{[]interface{}{"a", nil, float64(1)}, "a,,1"},
+		{map[string]interface{}{}, "[object Object]"},
+	}
+	for _, c := range strs {
+		if got := jsString(c.val); got != c.expected {
+			t.Errorf("jsString(%v) = %q, expected %q", c.val, got, c.expected)
+		}
+	}
+
+	if jsTruthy("") || jsTruthy(float64(0)) || jsTruthy(nil) || !jsTruthy(map[string]interface{}{}) {
+		t.Errorf("unexpected truthiness")
+	}
+	if got := jsTrim("\u00a0 a b\uFEFF\n"); got != "a b" {
+		t.Errorf("unexpected trim %q", got)
+	}
+	if !caseMatches([]interface{}{"shop", "store"}, "store") || caseMatches("1", float64(1)) || !caseMatches(nil, nil) {
+		t.Errorf("unexpected case matching")
+	}
+}
+
+func Test_leadingPseudo(t *testing.T) {
+	cases := []struct {
+		selector, pseudo, arg, rest string
+	}{
+		{`:frame(xpath://iframe[contains(@src,"pay")]) xpath://p`, "frame", `xpath://iframe[contains(@src,"pay")]`, "xpath://p"},
+		{`:shadow(xpath://div[contains(@id,'host')]) :shadow(.inner) p`, "shadow", `xpath://div[contains(@id,'host')]`, ":shadow(.inner) p"},
+		{`:frame("#a\)") b`, "frame", "#a)", "b"},
+		{`:frame(#inner)`, "frame", "#inner", ""},
+		{`div :frame(#inner)`, "", "", `div :frame(#inner)`},
+	}
+	for _, c := range cases {
+		pseudo, arg, rest, err := leadingPseudo(c.selector)
+		if err != nil || pseudo != c.pseudo || arg != c.arg || rest != c.rest {
+			t.Errorf("leadingPseudo(%q) = %q, %q, %q, %v", c.selector, pseudo, arg, rest, err)
+		}
+	}
+	if _, _, _, err := leadingPseudo(`:frame(xpath://iframe[contains(@src,"pay")]`); err == nil {
+		t.Errorf("expected an error on an unclosed pseudo")
+	}
+}
@@ -0,0 +1,120 @@
+package rpa
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// CrawlHTML crawls the html content offline, see CrawlHTMLWithOptions
+func (c *Crawler) CrawlHTML(html string, baseURL string, cfgOrFile interface{}) (*Result, error) {
+	return c.CrawlHTMLWithOptions(context.Background(), html, baseURL, cfgOrFile, CrawlOptions{})
+}
+
+// CrawlHTMLWithOptions crawls the html content as the page of baseURL without hitting the site,
+// such as an archived page. The relative links of the page are resolved against baseURL,
+// the content is written into a blank page if baseURL is empty.
+//
+// Every request of the page other than the content itself is blocked, and the external links are not followed,
+// the result keeps their urls like a crawl failing on them.
+// The downloads are disabled unless opts.AutoDownload is set, then they are saved into opts.DownloadRoot,
+// which is required so an offline crawl never writes into the download root of the config,
+// and the requests of the page go to the network.
+func (c *Crawler) CrawlHTMLWithOptions(ctx context.Context, html string, baseURL string, cfgOrFile interface{}, opts CrawlOptions) (*Result, error) {
+	return c.crawlOffline(ctx, baseURL, &html, cfgOrFile, opts)
+}
+
+// CrawlFile crawls a saved .html or .mhtml file offline, see CrawlFileWithOptions
+func (c *Crawler) CrawlFile(path string, cfgOrFile interface{}) (*Result, error) {
+	return c.CrawlFileWithOptions(context.Background(), path, cfgOrFile, CrawlOptions{})
+}
+
+// CrawlFileWithOptions crawls a saved .html or .mhtml file like CrawlHTMLWithOptions.
+// The file is opened by its file:// url, so the resources saved next to an html file
+// and the resources archived in an mhtml file are loaded, other requests are blocked.
+func (c *Crawler) CrawlFileWithOptions(ctx context.Context, path string, cfgOrFile interface{}, opts CrawlOptions) (*Result, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fileURL := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	if !strings.HasPrefix(fileURL.Path, "/") {
+		// a windows path such as C:/pages/a.html
+		fileURL.Path = "/" + fileURL.Path
+	}
+	return c.crawlOffline(ctx, fileURL.String(), nil, cfgOrFile, opts)
+}
+
+// crawlOffline opens link in a new tab blocking the network and crawls it,
+// html is served as the document of link if it's not nil.
+func (c *Crawler) crawlOffline(ctx context.Context, link string, html *string, cfgOrFile interface{}, opts CrawlOptions) (*Result, error) {
+	if opts.AutoDownload && opts.DownloadRoot == "" {
+		return nil, errors.New("the downloads of an offline crawl require CrawlOptions.DownloadRoot")
+	}
+	opts = opts.withDefaults()
+	opts.CloseTab = true
+	cfg, _, err := c.resolveCfg(ctx, cfgOrFile, opts.ConfigBase)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := c.Browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, canceledOr(ctx, err)
+	}
+
+	router := page.HijackRequests()
+	err = router.Add("*", "", func(h *rod.Hijack) {
+		u := h.Request.URL()
+		switch {
+		case html != nil && sameDocument(u, link):
+			h.Response.SetHeader("Content-Type", "text/html; charset=utf-8")
+			h.Response.SetBody(*html)
+		case u.Scheme == "file" || u.Scheme == "data" || opts.AutoDownload:
+			h.ContinueRequest(&proto.FetchContinueRequest{})
+		default:
+			h.Response.Fail(proto.NetworkErrorReasonBlockedByClient)
+		}
+	})
+	if err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+	go router.Run()
+	defer func() { _ = router.Stop() }()
+
+	p := page.Context(ctx)
+	if html != nil && link == "" {
+		err = p.SetDocumentContent(*html)
+	} else {
+		err = p.Navigate(link)
+	}
+	if err != nil {
+		_ = page.Close()
+		return nil, canceledOr(ctx, err)
+	}
+
+	return c.crawlPage(ctx, page, cfg, opts)
+}
+
+// sameDocument reports whether u is the url of link regardless of the fragment
+func sameDocument(u *url.URL, link string) bool {
+	target, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	a, b := *u, *target
+	a.Fragment, b.Fragment = "", ""
+	a.RawFragment, b.RawFragment = "", ""
+	for _, x := range []*url.URL{&a, &b} {
+		if x.Path == "" && x.Host != "" {
+			x.Path = "/"
+		}
+	}
+	return a.String() == b.String()
+}
@@ -0,0 +1,63 @@
+package rpa
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCrawlHTML(t *testing.T) {
+	c := &Crawler{Browser: engineBrowser(t)}
+	cfgFile := filepath.Join("testdata", "engine", "basic.json")
+	html, err := os.ReadFile(filepath.Join("testdata", "engine", "basic.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := c.CrawlHTML(string(html), "https://shop.test/list/", cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goods, _ := res.Data["goods"].([]interface{})
+	if res.Data["title"] != "Goods list" || len(goods) != 2 {
+		t.Fatalf("unexpected data %v", res.Data)
+	}
+	if detail := goods[0].(map[string]interface{})["detail"]; detail != "https://shop.test/detail/1" {
+		t.Errorf("expected the link resolved against the base url, got %v", detail)
+	}
+	if ext, ok := res.ExternalSection["/goods/detail"]; !ok || ext.ID != "detail" {
+		t.Errorf("expected the external link kept, got %v", res.ExternalSection)
+	}
+
+	fromFile, err := c.CrawlFile(filepath.Join("testdata", "engine", "basic.html"), cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromFile.Data["title"] != "Goods list" {
+		t.Errorf("unexpected data %v", fromFile.Data)
+	}
+
+	if _, err = c.CrawlHTMLWithOptions(context.Background(), string(html), "", cfgFile, CrawlOptions{AutoDownload: true}); err == nil {
+		t.Errorf("expected an error downloading without a download root")
+	}
+}
+
+func Test_sameDocument(t *testing.T) {
+	cases := []struct {
+		u, link  string
+		expected bool
+	}{
+		{"https://shop.test/", "https://shop.test", true},
+		{"https://shop.test/list/", "https://shop.test/list/#top", true},
+		{"https://shop.test/list/a.css", "https://shop.test/list/", false},
+		{"https://shop.test/list/?p=2", "https://shop.test/list/", false},
+	}
+	for _, c := range cases {
+		u, _ := url.Parse(c.u)
+		if got := sameDocument(u, c.link); got != c.expected {
+			t.Errorf("sameDocument(%q, %q) = %v, expected %v", c.u, c.link, got, c.expected)
+		}
+	}
+}
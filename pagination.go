@@ -0,0 +1,288 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+type NextTypeString string
+
+const (
+	NextClick NextTypeString = "click"
+	NextHref  NextTypeString = "href"
+)
+
+// Pagination describes how to follow the "next page" of paginated lists.
+//
+// It can be set on the CrawlerConfig to page the whole dataSection,
+// or on a top level list section of the dataSection to page only that section.
+type Pagination struct {
+	// Next is the selector of the next page link or button, paging stops when it is absent or invisible
+	Next string `json:"next"`
+
+	// NextType is how to turn to the next page, "click" the element or navigate to its "href", defaults to click
+	NextType NextTypeString `json:"nextType,omitempty"`
+
+	// MaxPages is the max number of pages to crawl including the first one, no limit if zero
+	MaxPages int `json:"maxPages,omitempty"`
+
+	// StopSelector stops paging when the selected element is visible, such as a disabled next button
+	StopSelector string `json:"stopSelector,omitempty"`
+
+	// StopRender is a JavaScript function body, paging stops when it returns true.
+	// It takes one fixed parameter: pageIndex, the 1-based index of the current page.
+	StopRender string `json:"stopRender,omitempty"`
+
+	// PageLoad is waited after turning to the next page
+	PageLoad *PageLoad `json:"pageLoad,omitempty"`
+
+	// IndexID is the key of the page index recorded in every list row, defaults to "pageIndex"
+	IndexID string `json:"indexId,omitempty"`
+}
+
+func (pg *Pagination) indexID() string {
+	if pg.IndexID == "" {
+		return "pageIndex"
+	}
+	return pg.IndexID
+}
+
+// paginate follows the section level paginations first, each one pages its own section,
+// then the config level pagination pages the rest of the sections.
+// The list results of every page are appended to result.Data.
+//
+// Every pagination starts from the first page: after a pagination turned the page,
+// the crawled url is loaded again and the actions of cfg are replayed before the next one.
+func (c *Crawler) paginate(ctx context.Context, page *rod.Page, cfg *CrawlerConfig, result *Result, opts CrawlOptions) error {
+	var runs []func() (bool, error)
+	var rest DataNodes
+	for _, node := range cfg.DataSection {
+		sec, ok := node.(*DataSection)
+		if !ok || sec.Pagination == nil {
+			rest = append(rest, node)
+			continue
+		}
+		runs = append(runs, func() (bool, error) {
+			return c.turnPages(ctx, page, sec.Pagination, DataNodes{sec}, result, opts)
+		})
+	}
+	if cfg.Pagination != nil && len(rest) > 0 {
+		runs = append(runs, func() (bool, error) {
+			return c.turnPages(ctx, page, cfg.Pagination, rest, result, opts)
+		})
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+
+	info, err := page.Info()
+	if err != nil {
+		return err
+	}
+	turned := false
+	for _, run := range runs {
+		if turned {
+			err = reloadFirstPage(ctx, page, info.URL, cfg, opts)
+			if err != nil {
+				return err
+			}
+		}
+		turned, err = run()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reloadFirstPage brings page back to the first page of the paginations: it loads link,
+// waits for the pageLoad of cfg and replays its actions.
+// The errors of the actions were reported by the first run of them, they are ignored.
+func reloadFirstPage(ctx context.Context, page *rod.Page, link string, cfg *CrawlerConfig, opts CrawlOptions) error {
+	err := page.Navigate(link)
+	if err != nil {
+		return err
+	}
+	err = page.WaitLoad()
+	if err != nil {
+		return err
+	}
+	err = waitPage(ctx, page, cfg.PageLoad.Sleep, cfg.PageLoad.Selector, cfg.PageLoad.Wait, opts.StableDuration, opts.WaitTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = runActions(ctx, page, cfg.Actions, "actions", opts)
+	return err
+}
+
+// turnPages crawls sections on every following page and appends the list rows to result.Data,
+// the errors of the appended rows are re-indexed to their position in the list.
+// It reports whether the page was turned, leaving page away from the first page.
+func (c *Crawler) turnPages(ctx context.Context, page *rod.Page, pg *Pagination, sections DataNodes, result *Result, opts CrawlOptions) (turned bool, err error) {
+	if pg.Next == "" {
+		return false, errors.New("pagination requires a next selector")
+	}
+	data := result.Data
+
+	indexID := pg.indexID()
+	// rows of the previous page in json, to find out if the next page loads anything new
+	lastRows := make(map[string]string)
+	for _, sec := range sections {
+		id := sec.Node().ID
+		if rows, ok := data[id].([]interface{}); ok {
+			lastRows[id] = rowsJson(rows)
+			markPageIndex(rows, indexID, 1)
+		}
+	}
+
+	var pageLoad PageLoad
+	if pg.PageLoad != nil {
+		pageLoad = *pg.PageLoad
+	}
+
+	subCfg := &CrawlerConfig{DataSection: sections}
+	for pageIndex := 1; pg.MaxPages <= 0 || pageIndex < pg.MaxPages; pageIndex++ {
+		if ctx.Err() != nil {
+			return turned, ctx.Err()
+		}
+
+		next, err := turnNextPage(ctx, page, pg, pageIndex)
+		turned = turned || next
+		if err != nil || !next {
+			return turned, err
+		}
+
+		err = waitPage(ctx, page, pageLoad.Sleep, pageLoad.Selector, pageLoad.Wait, opts.StableDuration, opts.WaitTimeout)
+		if err != nil {
+			return turned, err
+		}
+
+		res, err := extract(page, subCfg, opts.Engine)
+		if err != nil {
+			return turned, err
+		}
+		err = scrollSections(ctx, page, sections, res, opts)
+		if err != nil {
+			return turned, err
+		}
+
+		// the next page didn't load anything new, it's probably the last page
+		if !appendPage(result, res, lastRows, indexID, pageIndex+1) {
+			return turned, nil
+		}
+		if opts.Strict && len(result.Errors) > 0 {
+			return turned, firstError(result.Errors)
+		}
+	}
+	return turned, nil
+}
+
+// appendPage appends the list rows of res, the result of the page pageIndex, to result.Data,
+// and the errors of res to result.Errors.
+// It reports false and leaves result untouched if no list differs from the previous page in lastRows,
+// such as when the next button of the last page is still enabled.
+func appendPage(result, res *Result, lastRows map[string]string, indexID string, pageIndex int) bool {
+	pageRows := make(map[string][]interface{})
+	changed := false
+	for id, val := range res.Data {
+		rows, ok := val.([]interface{})
+		if !ok {
+			continue
+		}
+		pageRows[id] = rows
+		if rowsJson(rows) != lastRows[id] {
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	data := result.Data
+	others := res.Errors
+	for id, rows := range pageRows {
+		lastRows[id] = rowsJson(rows)
+
+		markPageIndex(rows, indexID, pageIndex)
+		prev, _ := data[id].([]interface{})
+		var rowErrs []CrawlError
+		others, rowErrs = splitRowErrors(others, id)
+		result.Errors = append(result.Errors, moveRowErrors(rowErrs, id, func(i int) (int, bool) {
+			return len(prev) + i, true
+		})...)
+		if prev != nil {
+			data[id] = append(prev, rows...)
+		} else {
+			data[id] = rows
+		}
+	}
+	result.Errors = append(result.Errors, others...)
+	return true
+}
+
+// turnNextPage checks the stop conditions then turns to the next page,
+// it returns false if there is no next page.
+func turnNextPage(ctx context.Context, page *rod.Page, pg *Pagination, pageIndex int) (bool, error) {
+	if pg.StopSelector != "" {
+		stop, err := elementVisibleContext(ctx, page, pg.StopSelector)
+		if err != nil || stop {
+			return false, err
+		}
+	}
+
+	if pg.StopRender != "" {
+		res, err := page.Eval(fmt.Sprintf(`(pageIndex) => { %s }`, pg.StopRender), pageIndex)
+		if err != nil {
+			return false, fmt.Errorf("pagination stopRender: %w", err)
+		}
+		if res.Value.Bool() {
+			return false, nil
+		}
+	}
+
+	visible, err := elementVisibleContext(ctx, page, pg.Next)
+	if err != nil || !visible {
+		return false, err
+	}
+	next, err := QueryElem(page, pg.Next)
+	if err != nil {
+		return false, err
+	}
+
+	if pg.NextType == NextHref {
+		href, err := next.Property("href")
+		if err != nil {
+			return false, err
+		}
+		link := href.String()
+		if link == "" || href.Nil() {
+			return false, nil
+		}
+		err = page.Navigate(link)
+		if err != nil {
+			return false, err
+		}
+		return true, page.WaitLoad()
+	}
+
+	return true, next.Click(proto.InputMouseButtonLeft, 1)
+}
+
+// markPageIndex records the page index in every row of a list result
+func markPageIndex(rows []interface{}, indexID string, pageIndex int) {
+	for _, row := range rows {
+		if r, ok := row.(map[string]interface{}); ok {
+			r[indexID] = pageIndex
+		}
+	}
+}
+
+func rowsJson(rows []interface{}) string {
+	b, _ := json.Marshal(rows)
+	return string(b)
+}
@@ -0,0 +1,76 @@
+package rpa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_sectionPagination(t *testing.T) {
+	var nodes DataNodes
+	err := json.Unmarshal([]byte(`[
+		{"id": "rows", "selector": ".row", "sectionType": "list", "items": [],
+			"pagination": {"next": "a.next", "nextType": "href", "maxPages": 3}},
+		{"id": "form", "selector": ".form", "sectionType": "form", "items": []}
+	]`), &nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg := nodes[0].(*DataSection).Pagination
+	if pg == nil || pg.Next != "a.next" || pg.NextType != NextHref || pg.MaxPages != 3 || pg.PageLoad != nil {
+		t.Errorf("unexpected pagination %+v", pg)
+	}
+	if pg.indexID() != "pageIndex" {
+		t.Errorf("unexpected default index id %q", pg.indexID())
+	}
+
+	if pg := nodes[1].(*DataSection).Pagination; pg != nil {
+		t.Errorf("expected no pagination, got %+v", pg)
+	}
+}
+
+func Test_markPageIndex(t *testing.T) {
+	rows := []interface{}{
+		map[string]interface{}{"title": "a"},
+		map[string]interface{}{"title": "b"},
+	}
+	markPageIndex(rows, "page", 2)
+	for _, row := range rows {
+		if row.(map[string]interface{})["page"] != 2 {
+			t.Errorf("page index not marked: %v", row)
+		}
+	}
+}
+
+func Test_appendPage(t *testing.T) {
+	page := func(names ...string) []interface{} {
+		rows := make([]interface{}, len(names))
+		for i, name := range names {
+			rows[i] = map[string]interface{}{"name": name}
+		}
+		return rows
+	}
+	result := &Result{Data: DictData{"goods": page("a", "b")}}
+	lastRows := map[string]string{"goods": rowsJson(page("a", "b"))}
+
+	second := &Result{
+		Data: DictData{"goods": page("c"), "title": "Goods"},
+		Errors: []CrawlError{
+			{Path: "goods/0/name", Stage: StageValueRender, Message: "bad name"},
+			{Path: "title", Stage: StageValueRender, Message: "bad title"},
+		},
+	}
+	if !appendPage(result, second, lastRows, "pageIndex", 2) {
+		t.Fatal("expected the second page to be appended")
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Path != "goods/2/name" || result.Errors[1].Path != "title" {
+		t.Errorf("expected the row error re-indexed and the other error kept, got %v", result.Errors)
+	}
+	// the next button of the last page is still enabled, the same page is crawled again
+	if appendPage(result, &Result{Data: DictData{"goods": page("c")}}, lastRows, "pageIndex", 3) {
+		t.Error("expected an unchanged page not to be appended")
+	}
+	rows := result.Data["goods"].([]interface{})
+	if len(rows) != 3 || rows[2].(map[string]interface{})["pageIndex"] != 2 {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
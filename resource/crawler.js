@@ -41,20 +41,231 @@ function assignDeep(target, ...sources) {
     delete result['__hash__'];
     return result;
 }
+const xpathPrefix = 'xpath:';
+function selectAll(doc, selector) {
+    if (!selector.startsWith(xpathPrefix)) {
+        if (stepPseudoPattern.test(selector)) {
+            return selectSteps(doc, selector);
+        }
+        return Array.from(doc.querySelectorAll(selector));
+    }
+    let ownerDoc = doc.ownerDocument || doc;
+    let snapshot = ownerDoc.evaluate(selector.slice(xpathPrefix.length), doc, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+    let nodes = [];
+    for (let i = 0; i < snapshot.snapshotLength; i++) {
+        let node = snapshot.snapshotItem(i);
+        if (node && node.nodeType === Node.ELEMENT_NODE) {
+            nodes.push(node);
+        }
+    }
+    return nodes;
+}
+function selectOne(doc, selector) {
+    if (!selector.startsWith(xpathPrefix) && !stepPseudoPattern.test(selector)) {
+        return doc.querySelector(selector);
+    }
+    return selectAll(doc, selector)[0] || null;
+}
+const stepPseudoPattern = /:(contains|matches|label)\(|:(next|parent|deep)(?![\w-])/;
+function parseSteps(selector) {
+    let steps = [];
+    let rest = selector;
+    let m;
+    while ((m = stepPseudoPattern.exec(rest))) {
+        let step = { css: rest.slice(0, m.index), pseudo: m[1] || m[2] };
+        rest = rest.slice(m.index + m[0].length);
+        if (m[1]) {
+            let { arg, end } = readPseudoArg(rest);
+            step.arg = arg;
+            rest = rest.slice(end);
+        }
+        steps.push(step);
+    }
+    steps.push({ css: rest });
+    return steps;
+}
+function readPseudoArg(str) {
+    let quoted = str.match(/^\s*(["'])((?:\\.|(?!\1).)*)\1\s*\)/);
+    if (quoted) {
+        return { arg: quoted[2].replace(/\\(.)/g, '$1'), end: quoted[0].length };
+    }
+    let depth = 0;
+    for (let i = 0; i < str.length; i++) {
+        if (str[i] === '\\') {
+            i++;
+        }
+        else if (str[i] === '(') {
+            depth++;
+        }
+        else if (str[i] === ')') {
+            if (depth === 0) {
+                return { arg: str.slice(0, i).trim(), end: i + 1 };
+            }
+            depth--;
+        }
+    }
+    throw new Error('unclosed pseudo argument: ' + str);
+}
+function compoundLength(css) {
+    let depth = 0;
+    for (let i = 0; i < css.length; i++) {
+        let c = css[i];
+        if (c === '\\') {
+            i++;
+        }
+        else if (c === '[' || c === '(') {
+            depth++;
+        }
+        else if (c === ']' || c === ')') {
+            depth--;
+        }
+        else if (depth === 0 && /[\s>+~]/.test(c)) {
+            return i;
+        }
+    }
+    return css.length;
+}
+let closedRoots = null;
+function shadowRootOf(elem) {
+    if (elem.shadowRoot) {
+        return elem.shadowRoot;
+    }
+    return (closedRoots && closedRoots.get(elem)) || null;
+}
+function deepRoots(node) {
+    let roots = [node];
+    let hosts = Array.from(node.querySelectorAll('*'));
+    if (node.nodeType === Node.ELEMENT_NODE) {
+        hosts.unshift(node);
+    }
+    hosts.forEach((el) => {
+        let root = shadowRootOf(el);
+        if (root) {
+            roots.push(...deepRoots(root));
+        }
+    });
+    return roots;
+}
+function uniqueElements(nodes) {
+    return Array.from(new Set(nodes.filter((n) => !!n)));
+}
+function normalizedText(elem) {
+    return (elem.textContent || '').replace(/\s+/g, ' ').trim();
+}
+function labelTarget(label) {
+    let next = label.nextElementSibling;
+    if (label.tagName === 'LABEL') {
+        return label.control || next;
+    }
+    if (label.tagName === 'DT') {
+        while (next && next.tagName !== 'DD') {
+            next = next.nextElementSibling;
+        }
+        return next;
+    }
+    if (next && next.tagName === 'TD') {
+        return next;
+    }
+    let cell = label;
+    let table = cell.closest('table');
+    if (!table) {
+        return null;
+    }
+    let rows = Array.from(table.rows);
+    for (let i = rows.indexOf(cell.parentElement) + 1; i < rows.length; i++) {
+        let below = rows[i].cells[cell.cellIndex];
+        if (below && below.tagName === 'TD') {
+            return below;
+        }
+    }
+    return null;
+}
+function selectSteps(doc, selector) {
+    let nodes = [doc];
+    let scoped = false;
+    let deep = false;
+    parseSteps(selector).forEach((step) => {
+        let css = step.css;
+        let filter = step.pseudo === 'contains' || step.pseudo === 'matches';
+        let innermost = filter && /(^|[\s>+~])$/.test(css) && (css !== '' || !scoped || deep);
+        if (innermost) {
+            css += '*';
+        }
+        if (css.trim() && deep) {
+            let found = [];
+            nodes.forEach((n) => deepRoots(n).forEach((root) => {
+                let rootCss = root === n && scoped ? ':scope ' + css.trim() : css.trim();
+                found.push(...Array.from(root.querySelectorAll(rootCss)));
+            }));
+            nodes = uniqueElements(found);
+            scoped = true;
+            deep = false;
+        }
+        else if (css.trim()) {
+            let rest = css;
+            if (scoped) {
+                let compound = css.slice(0, compoundLength(css));
+                rest = css.slice(compound.length).trim();
+                if (compound) {
+                    nodes = nodes.filter((n) => n.matches(compound));
+                }
+                rest = rest && ':scope ' + rest;
+            }
+            if (rest) {
+                let found = [];
+                nodes.forEach((n) => found.push(...Array.from(n.querySelectorAll(rest))));
+                nodes = uniqueElements(found);
+            }
+            scoped = true;
+        }
+        let elems = nodes;
+        switch (step.pseudo) {
+            case 'contains':
+                nodes = elems.filter((n) => normalizedText(n).includes(step.arg));
+                break;
+            case 'matches': {
+                let m = step.arg.match(/^\/(.*)\/([a-z]*)$/);
+                let re = m ? new RegExp(m[1], m[2]) : new RegExp(step.arg);
+                nodes = elems.filter((n) => re.test(normalizedText(n)));
+                break;
+            }
+            case 'label': {
+                let labels = [];
+                nodes.forEach((n) => labels.push(...Array.from(n.querySelectorAll('label, dt, th'))));
+                nodes = uniqueElements(labels.filter((l) => normalizedText(l).replace(/\s*[:：]$/, '') === step.arg).map(labelTarget));
+                scoped = true;
+                break;
+            }
+            case 'next':
+                nodes = uniqueElements(elems.map((n) => n.nextElementSibling));
+                break;
+            case 'parent':
+                nodes = uniqueElements(elems.map((n) => n.parentElement));
+                break;
+            case 'deep':
+                deep = true;
+                break;
+        }
+        if (innermost) {
+            nodes = nodes.filter((n) => !nodes.some((o) => o !== n && n.contains(o)));
+        }
+    });
+    return scoped ? nodes : [];
+}
 function replacePseudo(selector, parentElement = document) {
     let doc = parentElement;
     let ctxChanged = false;
-    let pseudoMatch = selector.match(/^:(frame|shadow)\((.+?)\)/);
+    let pseudoMatch = selector.match(/^:(frame|shadow)\(/);
     if (pseudoMatch) {
         let pseudoType = pseudoMatch[1];
-        let pseudoSelector = pseudoMatch[2];
-        let pseudoElem = parentElement.querySelector(pseudoSelector);
+        let { arg: pseudoSelector, end } = readPseudoArg(selector.slice(pseudoMatch[0].length));
+        let pseudoElem = selectOne(parentElement, pseudoSelector);
         if (pseudoElem) {
             doc =
                 pseudoType === 'frame'
-                    ? pseudoElem.contentWindow.document
-                    : pseudoElem.shadowRoot;
-            selector = selector.slice(pseudoMatch[0].length).trim();
+                    ? pseudoElem.contentDocument || document.createElement('div')
+                    : shadowRootOf(pseudoElem);
+            selector = selector.slice(pseudoMatch[0].length + end).trim();
             ctxChanged = true;
         }
     }
@@ -70,7 +281,7 @@ function queryElem(selectorString, parentElement = document, domRender) {
     }
     else {
         let { doc, selector } = replacePseudo(selectorString, parentElement);
-        secNode = doc.querySelector(selector);
+        secNode = selectOne(doc, selector);
     }
     if (domRender) {
         let rFn = new Function('dom', domRender);
@@ -85,7 +296,7 @@ function queryElems(selectorString, parentElement = document, domRender) {
     }
     else {
         let { doc, selector } = replacePseudo(selectorString, parentElement);
-        secNodes = Array.from(doc.querySelectorAll(selector));
+        secNodes = selectAll(doc, selector);
     }
     if (domRender) {
         let rFn = new Function('dom', domRender);
@@ -94,20 +305,34 @@ function queryElems(selectorString, parentElement = document, domRender) {
     return secNodes;
 }
 const externalDict = {};
+function dataPathOf(...segments) {
+    return segments.filter((s) => s !== '' && s !== undefined && s !== null).join('/');
+}
+function reportError(path, stage, selector, err) {
+    var _a;
+    let crawlError = {
+        path,
+        stage,
+        selector: selector !== null && selector !== void 0 ? selector : '',
+        message: (_a = err === null || err === void 0 ? void 0 : err.message) !== null && _a !== void 0 ? _a : String(err),
+    };
+    __result__.errors.push(crawlError);
+}
 function appendExternalSection(extObj) {
     let key = extObj.connect;
     if (!externalDict[key]) {
         externalDict[key] = extObj;
     }
 }
-function crawlList(sectionId, sectionElements, items, cncPath) {
+function crawlList(sectionId, sectionElements, items, cncPath, dataPath) {
     let dataArray = [];
     let renders = {};
-    sectionElements.forEach((element) => {
+    sectionElements.forEach((element, index) => {
         let data = {};
+        let rowPath = dataPathOf(dataPath, index);
         items.forEach((item) => {
             if ('itemType' in item) {
-                let { result, node } = crawItem(item, element);
+                let { result, node } = crawItem(item, element, dataPathOf(rowPath, item.id));
                 data[item.id] = result;
                 if (item.valueRender) {
                     try {
@@ -122,6 +347,7 @@ function crawlList(sectionId, sectionElements, items, cncPath) {
                     }
                     catch (err) {
                         console.error('[' + item.id + '.valueRender]', err);
+                        reportError(dataPathOf(rowPath, item.id), 'valueRender', item.selector, err);
                         data[item.id] = `err(${err.message})`;
                     }
                 }
@@ -135,7 +361,7 @@ function crawlList(sectionId, sectionElements, items, cncPath) {
                 }
             }
             else if ('sectionType' in item) {
-                let { result } = crawSection(item, element, cncPath + '/' + sectionId);
+                let { result } = crawSection(item, element, cncPath + '/' + sectionId, rowPath);
                 data[item.id] = result;
             }
         });
@@ -143,11 +369,11 @@ function crawlList(sectionId, sectionElements, items, cncPath) {
     });
     return dataArray;
 }
-function crawlForm(sectionId, sectionElement, items, cncPath) {
+function crawlForm(sectionId, sectionElement, items, cncPath, dataPath) {
     let dataObject = {};
     items.forEach((item) => {
         if ('itemType' in item) {
-            let { result, node } = crawItem(item, sectionElement);
+            let { result, node } = crawItem(item, sectionElement, dataPathOf(dataPath, item.id));
             dataObject[item.id] = result;
             if (item.valueRender) {
                 try {
@@ -159,6 +385,7 @@ function crawlForm(sectionId, sectionElement, items, cncPath) {
                 }
                 catch (err) {
                     console.error('[' + item.id + '.valueRender]', err);
+                    reportError(dataPathOf(dataPath, item.id), 'valueRender', item.selector, err);
                     dataObject[item.id] = `err(${err.message})`;
                 }
             }
@@ -172,25 +399,26 @@ function crawlForm(sectionId, sectionElement, items, cncPath) {
             }
         }
         else if ('sectionType' in item) {
-            let { result } = crawSection(item, sectionElement, cncPath + '/' + sectionId);
+            let { result } = crawSection(item, sectionElement, cncPath + '/' + sectionId, dataPath);
             dataObject[item.id] = result;
         }
     });
     return dataObject;
 }
-function crawSection(sectionItem, parentElement = document, cncPath = '') {
+function crawSection(sectionItem, parentElement = document, cncPath = '', dataPath = '') {
     let result;
     let node = parentElement;
+    let secPath = dataPathOf(dataPath, sectionItem.id);
     if (sectionItem.sectionType === 'form') {
         node = queryElem(sectionItem.selector, parentElement, sectionItem.domRender);
         if (node) {
-            let crwData = crawlForm(sectionItem.id, node, sectionItem.items, cncPath);
+            let crwData = crawlForm(sectionItem.id, node, sectionItem.items, cncPath, secPath);
             result = assignDeep(result !== null && result !== void 0 ? result : {}, crwData);
         }
     }
     else if (sectionItem.sectionType === 'list') {
         node = queryElems(sectionItem.selector, parentElement, sectionItem.domRender);
-        let crwData = crawlList(sectionItem.id, node, sectionItem.items, cncPath);
+        let crwData = crawlList(sectionItem.id, node, sectionItem.items, cncPath, secPath);
         if (sectionItem.filterRender) {
             try {
                 const renderFunc = new Function('val , i , arr', sectionItem.filterRender);
@@ -198,6 +426,7 @@ function crawSection(sectionItem, parentElement = document, cncPath = '') {
             }
             catch (err) {
                 console.error('[' + sectionItem.id + '.filterRender]', err);
+                reportError(secPath, 'filterRender', sectionItem.selector, err);
                 crwData = [err.message];
             }
         }
@@ -213,12 +442,13 @@ function crawSection(sectionItem, parentElement = document, cncPath = '') {
         }
         catch (err) {
             console.error('[' + sectionItem.id + '.valueRender]', err);
+            reportError(secPath, 'dataRender', sectionItem.selector, err);
             result = `err(${err.message})`;
         }
     }
     return { result, node };
 }
-function crawItem(item, parentElement = document) {
+function crawItem(item, parentElement = document, dataPath = '') {
     var _a, _b, _c, _d;
     let node = null;
     let result = null;
@@ -322,7 +552,7 @@ function crawItem(item, parentElement = document) {
             node = queryElem(item.selector, parentElement, item.domRender);
             let dnCfg = (_d = __config__.downloadSection) === null || _d === void 0 ? void 0 : _d.find((c) => c.id === item.downloadId);
             if (node && dnCfg) {
-                result = crawlDownloadItem(dnCfg, node);
+                result = crawlDownloadItem(dnCfg, node, dataPath);
             }
             break;
     }
@@ -336,7 +566,7 @@ function crawlByConfig(dataSection) {
             data[secItem.id] = result;
         }
         else if ('itemType' in secItem) {
-            let crwData = crawlForm('', document, [secItem], '');
+            let crwData = crawlForm('', document, [secItem], '', '');
             data[secItem.id] = crwData[secItem.id];
         }
     });
@@ -344,7 +574,7 @@ function crawlByConfig(dataSection) {
 }
 const crawlDownloadItem = (function () {
     let renders = {};
-    return function (dn, elem) {
+    return function (dn, elem, dataPath) {
         let fileInfo = {
             name: '',
             url: '',
@@ -366,6 +596,7 @@ const crawlDownloadItem = (function () {
                         }
                     } catch (err) {
                         console.error(dn.id + '-node[nameRender]', err);
+                        reportError(dataPath, 'nameRender', dn.selector, err);
                         fileInfo.error = err.message;
                     }
                 }
@@ -399,6 +630,7 @@ const crawlDownloadItem = (function () {
                     }
                     catch (err) {
                         console.error(dn.id + '-node[linkRender]', err);
+                        reportError(dataPath, 'linkRender', dn.selector, err);
                         fileInfo.error = err.message;
                     }
                 }
@@ -412,17 +644,27 @@ let __config__;
 let __result__ = {
     data: {},
     downloads: {},
+    errors: [],
 };
-function run(cfg) {
+function run(cfg, roots) {
     __config__ = cfg;
+    closedRoots = roots;
     let { dataSection, switchSection, downloadSection, downloadRoot } = cfg;
     if (dataSection) {
         __result__.data = crawlByConfig(dataSection);
     }
     if (switchSection) {
-        let swRender = new Function('data, config', switchSection.switchRender);
-        let swRes = swRender.call({ ...switchSection, ctx: { __config__, __result__ } }, __result__.data, cfg);
+        let swRes;
+        try {
+            let swRender = new Function('data, config', switchSection.switchRender);
+            swRes = swRender.call({ ...switchSection, ctx: { __config__, __result__ } }, __result__.data, cfg);
+        }
+        catch (err) {
+            console.error('[switchSection.switchRender]', err);
+            reportError('switchSection', 'switchRender', '', err);
+        }
         let matchedCase = switchSection.cases.find((c) => c.case === swRes || (c.case instanceof Array && c.case.indexOf(swRes) > -1));
+        __result__.matchedCase = matchedCase ? switchSection.cases.indexOf(matchedCase) : -1;
         if (matchedCase) {
             let swData = crawlByConfig(matchedCase.dataSection);
             __result__.data = assignDeep(__result__.data, swData);
@@ -455,7 +697,7 @@ function run(cfg) {
             let files = __result__.downloads[dn.id].files;
             elems.forEach((elem, i) => {
                 if (elem.getBoundingClientRect().height > 0) {
-                    let fileInfo = crawlDownloadItem(dn, elem);
+                    let fileInfo = crawlDownloadItem(dn, elem, dataPathOf('downloads', dn.id, 'files', files.length));
                     files.push(fileInfo);
                     if (dn.insertTo) {
                         const pathArray = dn.insertTo.split('.');
@@ -487,4 +729,127 @@ function run(cfg) {
     }
     return __result__;
 }
-
+function setValue(elem, value) {
+    var _a;
+    let setter = (_a = Object.getOwnPropertyDescriptor(Object.getPrototypeOf(elem), 'value')) === null || _a === void 0 ? void 0 : _a.set;
+    if (setter) {
+        setter.call(elem, value);
+    }
+    else {
+        elem.value = value;
+    }
+    elem.dispatchEvent(new Event('input', { bubbles: true }));
+    elem.dispatchEvent(new Event('change', { bubbles: true }));
+}
+function boxValue(item, elem, box) {
+    var _a;
+    if (elem.tagName === 'INPUT') {
+        return box.getAttribute((_a = item.valueProper) !== null && _a !== void 0 ? _a : 'value');
+    }
+    return item.valueProper ? elem.getAttribute(item.valueProper) : elem.innerText.trim();
+}
+function fillItem(item, value, parentElement) {
+    var _a;
+    switch (item.itemType) {
+        case 'textBox': {
+            let node = queryElem(item.selector, parentElement, item.domRender);
+            if (!node || (node.tagName !== 'INPUT' && node.tagName !== 'TEXTAREA')) {
+                throw new Error('no input or textarea');
+            }
+            setValue(node, value === null || value === undefined ? '' : String(value));
+            break;
+        }
+        case 'radioBox':
+        case 'checkBox': {
+            let type = item.itemType === 'radioBox' ? 'radio' : 'checkbox';
+            let values = item.itemType === 'radioBox' ? [value] : value;
+            if (!Array.isArray(values)) {
+                throw new Error('the value of a checkBox is not an array');
+            }
+            let wanted = values.filter((v) => v !== null && v !== undefined).map(String);
+            let found = [];
+            queryElems(item.selector, parentElement, item.domRender).forEach((elem) => {
+                var _a;
+                let box = (elem.tagName === 'INPUT' ? elem : elem.querySelector(`input[type=${type}]`));
+                if (!box) {
+                    return;
+                }
+                let val = (_a = boxValue(item, elem, box)) !== null && _a !== void 0 ? _a : '';
+                let checked = wanted.indexOf(val) > -1;
+                if (checked) {
+                    found.push(val);
+                }
+                if (box.checked !== checked && (checked || type === 'checkbox')) {
+                    box.click();
+                }
+            });
+            let missing = wanted.filter((v) => found.indexOf(v) < 0);
+            if (missing.length) {
+                throw new Error('no ' + type + ' of ' + JSON.stringify(missing.join(', ')));
+            }
+            break;
+        }
+        case 'dropBox': {
+            let node = queryElem(item.selector, parentElement, item.domRender);
+            if (!node || node.tagName !== 'SELECT') {
+                throw new Error('no select');
+            }
+            let byValue = ((_a = item.valueProper) === null || _a === void 0 ? void 0 : _a.toLowerCase()) === 'value';
+            let opt = Array.from(node.options).find((o) => (byValue ? o.value : o.text) === String(value));
+            if (!opt) {
+                throw new Error('no option ' + JSON.stringify(value));
+            }
+            setValue(node, opt.value);
+            break;
+        }
+    }
+}
+function fillByConfig(dataSection, data, parentElement, dataPath) {
+    dataSection === null || dataSection === void 0 ? void 0 : dataSection.forEach((node) => {
+        if (!data || !(node.id in data)) {
+            return;
+        }
+        let value = data[node.id];
+        let path = dataPathOf(dataPath, node.id);
+        try {
+            if ('sectionType' in node) {
+                if (node.sectionType === 'list') {
+                    if (node.filterRender) {
+                        throw new Error('a list having a filterRender can not be filled');
+                    }
+                    if (!Array.isArray(value)) {
+                        throw new Error('the value of a list is not an array');
+                    }
+                    let rows = queryElems(node.selector, parentElement, node.domRender);
+                    value.forEach((row, i) => {
+                        if (i < rows.length) {
+                            fillByConfig(node.items, row, rows[i], dataPathOf(path, i));
+                        }
+                        else {
+                            reportError(dataPathOf(path, i), 'fill', node.selector, new Error('no row element'));
+                        }
+                    });
+                }
+                else {
+                    let elem = queryElem(node.selector, parentElement, node.domRender);
+                    if (!elem) {
+                        throw new Error('no section element');
+                    }
+                    fillByConfig(node.items, value, elem, path);
+                }
+            }
+            else {
+                fillItem(node, value, parentElement);
+            }
+        }
+        catch (err) {
+            reportError(path, 'fill', node.selector, err);
+        }
+    });
+}
+function fill(cfg, data, roots) {
+    __config__ = cfg;
+    closedRoots = roots;
+    fillByConfig(cfg.dataSection, data, document, '');
+    return __result__.errors;
+}
@@ -5,6 +5,7 @@ type IExternal = import('./types').IExternal;
 type IResult = import('./types').IResult;
 type IValueItem = import('./types').IValueItem;
 type IDownloadSection = import('./types').IDownloadSection;
+type ICrawlError = import('./types').ICrawlError;
 
 function assignDeep(
 	target: any,
@@ -74,23 +75,290 @@ function assignDeep(
 // 	throw new Error('Unbalanced brackets');
 // }
 
+const xpathPrefix = 'xpath:';
+
+/**
+ * select the elements of a css selector, or of an xpath expression prefixed with 'xpath:', under doc
+ */
+function selectAll(doc: Element | Document | ShadowRoot, selector: string): Element[] {
+	if (!selector.startsWith(xpathPrefix)) {
+		if (stepPseudoPattern.test(selector)) {
+			return selectSteps(doc, selector);
+		}
+		return Array.from(doc.querySelectorAll(selector));
+	}
+	let ownerDoc = doc.ownerDocument || (doc as Document);
+	let snapshot = ownerDoc.evaluate(selector.slice(xpathPrefix.length), doc, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+	let nodes: Element[] = [];
+	for (let i = 0; i < snapshot.snapshotLength; i++) {
+		let node = snapshot.snapshotItem(i);
+		if (node && node.nodeType === Node.ELEMENT_NODE) {
+			nodes.push(node as Element);
+		}
+	}
+	return nodes;
+}
+
+function selectOne(doc: Element | Document | ShadowRoot, selector: string): Element | null {
+	if (!selector.startsWith(xpathPrefix) && !stepPseudoPattern.test(selector)) {
+		return doc.querySelector(selector);
+	}
+	return selectAll(doc, selector)[0] || null;
+}
+
+const stepPseudoPattern = /:(contains|matches|label)\(|:(next|parent|deep)(?![\w-])/;
+
+interface ISelectorStep {
+	css: string;
+	pseudo?: string;
+	arg?: string;
+}
+
+/**
+ * split the selector at the text and traversal pseudos, such as
+ * 'td:contains(Invoice No.):next' => [{css: 'td', pseudo: 'contains', arg: 'Invoice No.'}, {css: '', pseudo: 'next'}, {css: ''}]
+ */
+function parseSteps(selector: string): ISelectorStep[] {
+	let steps: ISelectorStep[] = [];
+	let rest = selector;
+	let m: RegExpExecArray | null;
+	while ((m = stepPseudoPattern.exec(rest))) {
+		let step: ISelectorStep = { css: rest.slice(0, m.index), pseudo: m[1] || m[2] };
+		rest = rest.slice(m.index + m[0].length);
+		if (m[1]) {
+			let { arg, end } = readPseudoArg(rest);
+			step.arg = arg;
+			rest = rest.slice(end);
+		}
+		steps.push(step);
+	}
+	steps.push({ css: rest });
+	return steps;
+}
+
+/**
+ * read the argument of a pseudo from the text following its '(', it's either quoted or has balanced brackets
+ */
+function readPseudoArg(str: string): { arg: string; end: number } {
+	let quoted = str.match(/^\s*(["'])((?:\\.|(?!\1).)*)\1\s*\)/);
+	if (quoted) {
+		return { arg: quoted[2].replace(/\\(.)/g, '$1'), end: quoted[0].length };
+	}
+	let depth = 0;
+	for (let i = 0; i < str.length; i++) {
+		if (str[i] === '\\') {
+			i++;
+		} else if (str[i] === '(') {
+			depth++;
+		} else if (str[i] === ')') {
+			if (depth === 0) {
+				return { arg: str.slice(0, i).trim(), end: i + 1 };
+			}
+			depth--;
+		}
+	}
+	throw new Error('unclosed pseudo argument: ' + str);
+}
+
+/**
+ * the length of the leading compound selector, such as 'td.price' of 'td.price > span'
+ */
+function compoundLength(css: string): number {
+	let depth = 0;
+	for (let i = 0; i < css.length; i++) {
+		let c = css[i];
+		if (c === '\\') {
+			i++;
+		} else if (c === '[' || c === '(') {
+			depth++;
+		} else if (c === ']' || c === ')') {
+			depth--;
+		} else if (depth === 0 && /[\s>+~]/.test(c)) {
+			return i;
+		}
+	}
+	return css.length;
+}
+
+/**
+ * the closed shadow roots of the document by their hosts, handed by Go to run and fill, see exposeClosedShadowRoots
+ */
+let closedRoots: WeakMap<Element, ShadowRoot> | null = null;
+
+/**
+ * the shadow root of the element, a closed one is looked up in closedRoots
+ */
+function shadowRootOf(elem: Element): ShadowRoot | null {
+	if (elem.shadowRoot) {
+		return elem.shadowRoot;
+	}
+	return (closedRoots && closedRoots.get(elem)) || null;
+}
+
+/**
+ * the node and the shadow roots under it, through all the shadow boundaries
+ */
+function deepRoots(node: Element | Document | ShadowRoot): (Element | Document | ShadowRoot)[] {
+	let roots = [node];
+	let hosts = Array.from(node.querySelectorAll('*'));
+	if (node.nodeType === Node.ELEMENT_NODE) {
+		hosts.unshift(node as Element);
+	}
+	hosts.forEach((el) => {
+		let root = shadowRootOf(el);
+		if (root) {
+			roots.push(...deepRoots(root));
+		}
+	});
+	return roots;
+}
+
+function uniqueElements(nodes: (Element | null)[]): Element[] {
+	return Array.from(new Set(nodes.filter((n) => !!n) as Element[]));
+}
+
+function normalizedText(elem: Element): string {
+	return (elem.textContent || '').replace(/\s+/g, ' ').trim();
+}
+
+/**
+ * the value element of a label, dt or th element: the control of the label, the dd of the dt,
+ * the cell after a row header or the cell below a column header
+ */
+function labelTarget(label: Element): Element | null {
+	let next = label.nextElementSibling;
+	if (label.tagName === 'LABEL') {
+		return (label as HTMLLabelElement).control || next;
+	}
+	if (label.tagName === 'DT') {
+		while (next && next.tagName !== 'DD') {
+			next = next.nextElementSibling;
+		}
+		return next;
+	}
+	if (next && next.tagName === 'TD') {
+		return next;
+	}
+	let cell = label as HTMLTableCellElement;
+	let table = cell.closest('table');
+	if (!table) {
+		return null;
+	}
+	let rows = Array.from(table.rows);
+	for (let i = rows.indexOf(cell.parentElement as HTMLTableRowElement) + 1; i < rows.length; i++) {
+		let below = rows[i].cells[cell.cellIndex];
+		if (below && below.tagName === 'TD') {
+			return below;
+		}
+	}
+	return null;
+}
+
+/**
+ * select the elements of a css selector having the pseudos:
+ * :contains(text) and :matches(regex) keep the elements whose text has the text or matches the regex, the innermost ones if no element is given,
+ * :label(text) selects the value elements of the label, dt and th elements of the text,
+ * :next and :parent select the next sibling and the parent elements,
+ * :deep makes the css following it select through all the shadow boundaries.
+ * The css following a pseudo selects under the elements selected so far.
+ */
+function selectSteps(doc: Element | Document | ShadowRoot, selector: string): Element[] {
+	let nodes: (Element | Document | ShadowRoot)[] = [doc];
+	let scoped = false;
+	let deep = false;
+	parseSteps(selector).forEach((step) => {
+		let css = step.css;
+		let filter = step.pseudo === 'contains' || step.pseudo === 'matches';
+		let innermost = filter && /(^|[\s>+~])$/.test(css) && (css !== '' || !scoped || deep);
+		if (innermost) {
+			css += '*';
+		}
+
+		if (css.trim() && deep) {
+			let found: Element[] = [];
+			nodes.forEach((n) =>
+				deepRoots(n).forEach((root) => {
+					let rootCss = root === n && scoped ? ':scope ' + css.trim() : css.trim();
+					found.push(...Array.from(root.querySelectorAll(rootCss)));
+				})
+			);
+			nodes = uniqueElements(found);
+			scoped = true;
+			deep = false;
+		} else if (css.trim()) {
+			let rest = css;
+			if (scoped) {
+				let compound = css.slice(0, compoundLength(css));
+				rest = css.slice(compound.length).trim();
+				if (compound) {
+					nodes = (nodes as Element[]).filter((n) => n.matches(compound));
+				}
+				rest = rest && ':scope ' + rest;
+			}
+			if (rest) {
+				let found: Element[] = [];
+				nodes.forEach((n) => found.push(...Array.from(n.querySelectorAll(rest))));
+				nodes = uniqueElements(found);
+			}
+			scoped = true;
+		}
+
+		let elems = nodes as Element[];
+		switch (step.pseudo) {
+			case 'contains':
+				nodes = elems.filter((n) => normalizedText(n).includes(step.arg!));
+				break;
+			case 'matches': {
+				let m = step.arg!.match(/^\/(.*)\/([a-z]*)$/);
+				let re = m ? new RegExp(m[1], m[2]) : new RegExp(step.arg!);
+				nodes = elems.filter((n) => re.test(normalizedText(n)));
+				break;
+			}
+			case 'label': {
+				let labels: Element[] = [];
+				nodes.forEach((n) => labels.push(...Array.from(n.querySelectorAll('label, dt, th'))));
+				nodes = uniqueElements(
+					labels.filter((l) => normalizedText(l).replace(/\s*[:：]$/, '') === step.arg).map(labelTarget)
+				);
+				scoped = true;
+				break;
+			}
+			case 'next':
+				nodes = uniqueElements(elems.map((n) => n.nextElementSibling));
+				break;
+			case 'parent':
+				nodes = uniqueElements(elems.map((n) => n.parentElement));
+				break;
+			case 'deep':
+				deep = true;
+				break;
+		}
+		if (innermost) {
+			nodes = (nodes as Element[]).filter((n) => !nodes.some((o) => o !== n && n.contains(o as Element)));
+		}
+	});
+	return scoped ? (nodes as Element[]) : [];
+}
+
 function replacePseudo(
 	selector: string,
 	parentElement: Element | Document | ShadowRoot = document
 ): { doc: Element | Document | ShadowRoot; selector: string; ctxChanged: boolean } {
 	let doc = parentElement;
 	let ctxChanged = false;
-	let pseudoMatch = selector.match(/^:(frame|shadow)\((.+?)\)/);
+	let pseudoMatch = selector.match(/^:(frame|shadow)\(/);
 	if (pseudoMatch) {
 		let pseudoType = pseudoMatch[1];
-		let pseudoSelector = pseudoMatch[2];
-		let pseudoElem = parentElement.querySelector(pseudoSelector);
+		// the argument ends at its balanced ')', an xpath may have brackets of its own
+		let { arg: pseudoSelector, end } = readPseudoArg(selector.slice(pseudoMatch[0].length));
+		let pseudoElem = selectOne(parentElement, pseudoSelector);
 		if (pseudoElem) {
+			// the document of a cross-origin frame is null, its nodes are crawled from Go, see crawlCrossFrames
 			doc =
 				pseudoType === 'frame'
-					? (pseudoElem as HTMLIFrameElement).contentWindow!.document
-					: (pseudoElem as HTMLSlotElement).shadowRoot!;
-			selector = selector.slice(pseudoMatch[0].length).trim();
+					? (pseudoElem as HTMLIFrameElement).contentDocument || document.createElement('div')
+					: shadowRootOf(pseudoElem)!;
+			selector = selector.slice(pseudoMatch[0].length + end).trim();
 			ctxChanged = true;
 		}
 	}
@@ -110,7 +378,7 @@ function queryElem(
 		secNode = parentElement as Element;
 	} else {
 		let { doc, selector } = replacePseudo(selectorString, parentElement);
-		secNode = doc.querySelector(selector);
+		secNode = selectOne(doc, selector);
 	}
 
 	if (domRender) {
@@ -130,7 +398,7 @@ function queryElems(
 		secNodes = [parentElement as Element];
 	} else {
 		let { doc, selector } = replacePseudo(selectorString, parentElement);
-		secNodes = Array.from(doc.querySelectorAll(selector));
+		secNodes = selectAll(doc, selector);
 	}
 
 	if (domRender) {
@@ -142,6 +410,23 @@ function queryElems(
 
 const externalDict: Record<string, IExternal> = {};
 
+/**
+ * join the non-empty segments to a slash separated data path, such as 'list/0/title'
+ */
+function dataPathOf(...segments: (string | number)[]): string {
+	return segments.filter((s) => s !== '' && s !== undefined && s !== null).join('/');
+}
+
+function reportError(path: string, stage: string, selector: string | undefined, err: any) {
+	let crawlError: ICrawlError = {
+		path,
+		stage,
+		selector: selector ?? '',
+		message: err?.message ?? String(err),
+	};
+	__result__.errors!.push(crawlError);
+}
+
 function appendExternalSection(extObj: IExternal) {
 	let key = extObj.connect;
 	if (!externalDict[key]) {
@@ -153,16 +438,18 @@ function crawlList(
 	sectionId: string,
 	sectionElements: Element[],
 	items: (IValueItem | IDataSection)[],
-	cncPath: string
+	cncPath: string,
+	dataPath: string
 ): any[] {
 	let dataArray: any[] = [];
 	let renders: Record<string, Function> = {};
 
-	sectionElements.forEach((element) => {
+	sectionElements.forEach((element, index) => {
 		let data: any = {};
+		let rowPath = dataPathOf(dataPath, index);
 		items.forEach((item) => {
 			if ('itemType' in item) {
-				let { result, node } = crawItem(item, element);
+				let { result, node } = crawItem(item, element, dataPathOf(rowPath, item.id));
 				data[item.id] = result;
 
 				if (item.valueRender) {
@@ -181,6 +468,7 @@ function crawlList(
 						}
 					} catch (err: any) {
 						console.error('[' + item.id + '.valueRender]', err);
+						reportError(dataPathOf(rowPath, item.id), 'valueRender', item.selector, err);
 						data[item.id] = `err(${err.message})`;
 					}
 				}
@@ -194,7 +482,7 @@ function crawlList(
 					});
 				}
 			} else if ('sectionType' in item) {
-				let { result } = crawSection(item, element, cncPath + '/' + sectionId);
+				let { result } = crawSection(item, element, cncPath + '/' + sectionId, rowPath);
 				data[item.id] = result;
 			}
 		});
@@ -207,12 +495,13 @@ function crawlForm(
 	sectionId: string,
 	sectionElement: Element | Document | ShadowRoot,
 	items: (IValueItem | IDataSection)[],
-	cncPath: string
+	cncPath: string,
+	dataPath: string
 ): any {
 	let dataObject: any = {};
 	items.forEach((item) => {
 		if ('itemType' in item) {
-			let { result, node } = crawItem(item, sectionElement);
+			let { result, node } = crawItem(item, sectionElement, dataPathOf(dataPath, item.id));
 			dataObject[item.id] = result;
 
 			if (item.valueRender) {
@@ -228,6 +517,7 @@ function crawlForm(
 					}
 				} catch (err: any) {
 					console.error('[' + item.id + '.valueRender]', err);
+					reportError(dataPathOf(dataPath, item.id), 'valueRender', item.selector, err);
 					dataObject[item.id] = `err(${err.message})`;
 				}
 			}
@@ -241,7 +531,7 @@ function crawlForm(
 				});
 			}
 		} else if ('sectionType' in item) {
-			let { result } = crawSection(item, sectionElement, cncPath + '/' + sectionId);
+			let { result } = crawSection(item, sectionElement, cncPath + '/' + sectionId, dataPath);
 			dataObject[item.id] = result;
 		}
 	});
@@ -259,25 +549,28 @@ function crawlForm(
 function crawSection(
 	sectionItem: IDataSection,
 	parentElement: Element | Document | ShadowRoot = document,
-	cncPath = ''
+	cncPath = '',
+	dataPath = ''
 ) {
 	let result: any;
 	let node: Element | Element[] | Document | ShadowRoot | null = parentElement;
+	let secPath = dataPathOf(dataPath, sectionItem.id);
 	if (sectionItem.sectionType === 'form') {
 		node = queryElem(sectionItem.selector, parentElement, sectionItem.domRender);
 		if (node) {
-			let crwData = crawlForm(sectionItem.id, node, sectionItem.items, cncPath);
+			let crwData = crawlForm(sectionItem.id, node, sectionItem.items, cncPath, secPath);
 			result = assignDeep(result ?? {}, crwData);
 		}
 	} else if (sectionItem.sectionType === 'list') {
 		node = queryElems(sectionItem.selector, parentElement, sectionItem.domRender);
-		let crwData = crawlList(sectionItem.id, node, sectionItem.items, cncPath);
+		let crwData = crawlList(sectionItem.id, node, sectionItem.items, cncPath, secPath);
 		if (sectionItem.filterRender) {
 			try {
 				const renderFunc = new Function('val , i , arr', sectionItem.filterRender) as () => boolean;
 				crwData = crwData.filter(renderFunc);
 			} catch (err: any) {
 				console.error('[' + sectionItem.id + '.filterRender]', err);
+				reportError(secPath, 'filterRender', sectionItem.selector, err);
 				crwData = [err.message];
 			}
 		}
@@ -292,6 +585,7 @@ function crawSection(
 			}
 		} catch (err: any) {
 			console.error('[' + sectionItem.id + '.valueRender]', err);
+			reportError(secPath, 'dataRender', sectionItem.selector, err);
 			result = `err(${err.message})`;
 		}
 	}
@@ -299,7 +593,7 @@ function crawSection(
 	return { result, node };
 }
 
-function crawItem(item: IValueItem, parentElement: Element | Document | ShadowRoot = document) {
+function crawItem(item: IValueItem, parentElement: Element | Document | ShadowRoot = document, dataPath = '') {
 	let node: Element | Element[] | null = null;
 	let result: string | (string | null)[] | IFileInfo | null = null;
 
@@ -396,7 +690,7 @@ function crawItem(item: IValueItem, parentElement: Element | Document | ShadowRo
 			node = queryElem(item.selector, parentElement, item.domRender);
 			let dnCfg = __config__.downloadSection?.find((c) => c.id === item.downloadId);
 			if (node && dnCfg) {
-				result = crawlDownloadItem(dnCfg, node);
+				result = crawlDownloadItem(dnCfg, node, dataPath);
 			}
 			break;
 	}
@@ -412,7 +706,7 @@ function crawlByConfig(dataSection: (IValueItem | IDataSection)[]) {
 			let { result } = crawSection(secItem);
 			data[secItem.id] = result;
 		} else if ('itemType' in secItem) {
-			let crwData = crawlForm('', document, [secItem], '');
+			let crwData = crawlForm('', document, [secItem], '', '');
 			data[secItem.id] = crwData[secItem.id];
 		}
 	});
@@ -420,10 +714,10 @@ function crawlByConfig(dataSection: (IValueItem | IDataSection)[]) {
 	return data;
 }
 
-const crawlDownloadItem: (dn: IDownloadSection, elem: Element) => IFileInfo = (function () {
+const crawlDownloadItem: (dn: IDownloadSection, elem: Element, dataPath: string) => IFileInfo = (function () {
 	let renders: Record<string, Function> = {};
 
-	return function (dn: IDownloadSection, elem: Element): IFileInfo {
+	return function (dn: IDownloadSection, elem: Element, dataPath: string): IFileInfo {
 		let fileInfo: IFileInfo = {
 			name: '',
 			url: '',
@@ -445,6 +739,7 @@ const crawlDownloadItem: (dn: IDownloadSection, elem: Element) => IFileInfo = (f
 						}
 					} catch (err: any) {
 						console.error(dn.id + '-node[nameRender]', err);
+						reportError(dataPath, 'nameRender', dn.selector, err);
 						fileInfo.error = err.message;
 					}
 				}
@@ -478,6 +773,7 @@ const crawlDownloadItem: (dn: IDownloadSection, elem: Element) => IFileInfo = (f
 						}
 					} catch (err: any) {
 						console.error(dn.id + '-node[linkRender]', err);
+						reportError(dataPath, 'linkRender', dn.selector, err);
 						fileInfo.error = err.message;
 					}
 				}
@@ -493,10 +789,12 @@ let __config__: IConfig;
 let __result__: IResult = {
 	data: {},
 	downloads: {},
+	errors: [],
 };
 
-function run(cfg: IConfig) {
+function run(cfg: IConfig, roots: WeakMap<Element, ShadowRoot> | null) {
 	__config__ = cfg;
+	closedRoots = roots;
 	let { dataSection, switchSection, downloadSection, downloadRoot } = cfg;
 
 	if (dataSection) {
@@ -504,11 +802,19 @@ function run(cfg: IConfig) {
 	}
 
 	if (switchSection) {
-		let swRender = new Function('data, config', switchSection.switchRender);
-		let swRes = swRender.call({ ...switchSection, ctx: { __config__, __result__ } }, __result__.data, cfg);
+		let swRes: any;
+		try {
+			let swRender = new Function('data, config', switchSection.switchRender);
+			swRes = swRender.call({ ...switchSection, ctx: { __config__, __result__ } }, __result__.data, cfg);
+		} catch (err: any) {
+			console.error('[switchSection.switchRender]', err);
+			reportError('switchSection', 'switchRender', '', err);
+		}
 		let matchedCase = switchSection.cases.find(
 			(c) => c.case === swRes || (c.case instanceof Array && (c.case as string[]).indexOf(swRes) > -1)
 		);
+		// Go runs the actions of the matched case, see extractSwitchActions
+		__result__.matchedCase = matchedCase ? switchSection.cases.indexOf(matchedCase) : -1;
 		if (matchedCase) {
 			let swData = crawlByConfig(matchedCase.dataSection);
 			__result__.data = assignDeep(__result__.data, swData);
@@ -551,7 +857,7 @@ function run(cfg: IConfig) {
 			let files = __result__.downloads![dn.id].files;
 			elems.forEach((elem, i) => {
 				if (elem.getBoundingClientRect().height > 0) {
-					let fileInfo: IFileInfo = crawlDownloadItem(dn, elem);
+					let fileInfo: IFileInfo = crawlDownloadItem(dn, elem, dataPathOf('downloads', dn.id, 'files', files.length));
 					files.push(fileInfo);
 					if (dn.insertTo) {
 						const pathArray = dn.insertTo.split('.');
@@ -585,3 +891,130 @@ function run(cfg: IConfig) {
 
 	return __result__;
 }
+
+function setValue(elem: HTMLInputElement | HTMLTextAreaElement | HTMLSelectElement, value: string) {
+	// the setter of the prototype, as frameworks like React track the value set on the element
+	let setter = Object.getOwnPropertyDescriptor(Object.getPrototypeOf(elem), 'value')?.set;
+	if (setter) {
+		setter.call(elem, value);
+	} else {
+		elem.value = value;
+	}
+	elem.dispatchEvent(new Event('input', { bubbles: true }));
+	elem.dispatchEvent(new Event('change', { bubbles: true }));
+}
+
+function boxValue(item: IValueItem, elem: HTMLElement, box: HTMLInputElement) {
+	if (elem.tagName === 'INPUT') {
+		return box.getAttribute(item.valueProper ?? 'value');
+	}
+	return item.valueProper ? elem.getAttribute(item.valueProper) : elem.innerText.trim();
+}
+
+function fillItem(item: IValueItem, value: any, parentElement: Element | Document | ShadowRoot) {
+	switch (item.itemType) {
+		case 'textBox': {
+			let node = queryElem(item.selector, parentElement, item.domRender) as HTMLInputElement | null;
+			if (!node || (node.tagName !== 'INPUT' && node.tagName !== 'TEXTAREA')) {
+				throw new Error('no input or textarea');
+			}
+			setValue(node, value === null || value === undefined ? '' : String(value));
+			break;
+		}
+		case 'radioBox':
+		case 'checkBox': {
+			let type = item.itemType === 'radioBox' ? 'radio' : 'checkbox';
+			let values = item.itemType === 'radioBox' ? [value] : value;
+			if (!Array.isArray(values)) {
+				throw new Error('the value of a checkBox is not an array');
+			}
+			let wanted = values.filter((v) => v !== null && v !== undefined).map(String);
+			let found: string[] = [];
+			(queryElems(item.selector, parentElement, item.domRender) as HTMLElement[]).forEach((elem) => {
+				let box = (elem.tagName === 'INPUT' ? elem : elem.querySelector(`input[type=${type}]`)) as HTMLInputElement | null;
+				if (!box) {
+					return;
+				}
+				let val = boxValue(item, elem, box) ?? '';
+				let checked = wanted.indexOf(val) > -1;
+				if (checked) {
+					found.push(val);
+				}
+				// a radio is unchecked by checking another one
+				if (box.checked !== checked && (checked || type === 'checkbox')) {
+					box.click();
+				}
+			});
+			let missing = wanted.filter((v) => found.indexOf(v) < 0);
+			if (missing.length) {
+				throw new Error('no ' + type + ' of ' + JSON.stringify(missing.join(', ')));
+			}
+			break;
+		}
+		case 'dropBox': {
+			let node = queryElem(item.selector, parentElement, item.domRender) as HTMLSelectElement | null;
+			if (!node || node.tagName !== 'SELECT') {
+				throw new Error('no select');
+			}
+			let byValue = item.valueProper?.toLowerCase() === 'value';
+			let opt = Array.from(node.options).find((o) => (byValue ? o.value : o.text) === String(value));
+			if (!opt) {
+				throw new Error('no option ' + JSON.stringify(value));
+			}
+			setValue(node, opt.value);
+			break;
+		}
+	}
+}
+
+function fillByConfig(
+	dataSection: (IValueItem | IDataSection)[],
+	data: Record<string, any>,
+	parentElement: Element | Document | ShadowRoot,
+	dataPath: string
+) {
+	dataSection?.forEach((node) => {
+		if (!data || !(node.id in data)) {
+			return;
+		}
+		let value = data[node.id];
+		let path = dataPathOf(dataPath, node.id);
+		try {
+			if ('sectionType' in node) {
+				if (node.sectionType === 'list') {
+					if (node.filterRender) {
+						throw new Error('a list having a filterRender can not be filled');
+					}
+					if (!Array.isArray(value)) {
+						throw new Error('the value of a list is not an array');
+					}
+					let rows = queryElems(node.selector, parentElement, node.domRender) as Element[];
+					value.forEach((row, i) => {
+						if (i < rows.length) {
+							fillByConfig(node.items, row, rows[i], dataPathOf(path, i));
+						} else {
+							reportError(dataPathOf(path, i), 'fill', node.selector, new Error('no row element'));
+						}
+					});
+				} else {
+					let elem = queryElem(node.selector, parentElement, node.domRender) as Element | null;
+					if (!elem) {
+						throw new Error('no section element');
+					}
+					fillByConfig(node.items, value, elem, path);
+				}
+			} else {
+				fillItem(node, value, parentElement);
+			}
+		} catch (err: any) {
+			reportError(path, 'fill', node.selector, err);
+		}
+	});
+}
+
+function fill(cfg: IConfig, data: Record<string, any>, roots: WeakMap<Element, ShadowRoot> | null) {
+	__config__ = cfg;
+	closedRoots = roots;
+	fillByConfig(cfg.dataSection, data, document, '');
+	return __result__.errors;
+}
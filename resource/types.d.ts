@@ -45,6 +45,141 @@ export interface IConfig {
 		 */
 		sleep?: number;
 	};
+
+	/**
+	 * Pagination of the whole dataSection, optional. Sections having their own pagination are paged separately.
+	 */
+	pagination?: IPagination;
+
+	/**
+	 * Steps run by the Go side after pageLoad and before the page is crawled, optional
+	 */
+	actions?: IAction[];
+
+	/**
+	 * How to log in when the page is logged out, checked by the Go side before pageLoad, optional
+	 */
+	login?: ILogin;
+}
+
+/**
+ * A login form. The session cookies are saved to sessionFile once logged in, and restored when the page is logged out.
+ */
+export interface ILogin {
+	/**
+	 * Url of the login page, the login form is expected on the crawled page if it's empty
+	 */
+	url?: string;
+
+	/**
+	 * Selector of an element visible only when logged out, such as the login form
+	 */
+	loggedOut: string;
+
+	/**
+	 * Inputs of the login form, the value of each one is either value, the environment variable env,
+	 * or the secret of the secret provider of the crawler
+	 */
+	fields: { selector: string; value?: string; env?: string; secret?: string }[];
+
+	/**
+	 * Selector of the submit button, Enter is pressed in the last field if it's empty
+	 */
+	submit?: string;
+
+	/**
+	 * Selector of an element showing once logged in
+	 */
+	success: string;
+
+	/**
+	 * Seconds to wait for the form and for the success selector, 30 by default
+	 */
+	timeout?: number;
+
+	/**
+	 * JSON file of the session cookies, optional
+	 */
+	sessionFile?: string;
+}
+
+/**
+ * A step run on the page before it's crawled, such as opening a tab or expanding a panel.
+ * A failed step is reported in the errors of the result with the 'action' stage, the following steps still run.
+ */
+export interface IAction {
+	type: 'click' | 'input' | 'select' | 'press' | 'hover' | 'scroll' | 'waitShow' | 'waitHide' | 'sleep' | 'eval';
+
+	/**
+	 * Selector of the element, the same syntax as the selector of a node.
+	 * Required by click, input, select, hover, waitShow and waitHide.
+	 * press focuses the element and scroll scrolls it into view if it's set, eval runs the script with the element as this.
+	 */
+	selector?: string;
+
+	/**
+	 * The text of input, the value or the text of the option of select, the key of press such as 'Enter' or 'Control+a',
+	 * the pixels to scroll down without a selector, to the bottom if empty
+	 */
+	value?: string;
+
+	/**
+	 * JavaScript function body of eval, a returned promise is awaited
+	 */
+	script?: string;
+
+	/**
+	 * Seconds to wait for the element, 30 by default
+	 */
+	timeout?: number;
+
+	/**
+	 * Milliseconds to wait of sleep, unlike the sleep of pageLoad which is in seconds
+	 */
+	sleep?: number;
+}
+
+/**
+ * Pagination configuration, executed by the Go side after the first page is crawled.
+ * List results of every page are concatenated into one section result.
+ */
+export interface IPagination {
+	/**
+	 * CSS selector of the next page link or button, paging stops when it is absent or invisible
+	 */
+	next: string;
+
+	/**
+	 * How to turn to the next page: click the element, or navigate to its href. Defaults to 'click'
+	 */
+	nextType?: 'click' | 'href';
+
+	/**
+	 * Max number of pages to crawl including the first one, no limit if 0
+	 */
+	maxPages?: number;
+
+	/**
+	 * Paging stops when the element of this CSS selector is visible, such as a disabled next button
+	 */
+	stopSelector?: string;
+
+	/**
+	 * JavaScript function string, paging stops when it returns true.
+	 *
+	 * Function signature: It takes one fixed parameter: pageIndex, the 1-based index of the current page.
+	 */
+	stopRender?: string;
+
+	/**
+	 * Node configuration for determining whether the next page has finished loading
+	 */
+	pageLoad?: IConfig['pageLoad'];
+
+	/**
+	 * Key of the page index recorded in every list row, defaults to 'pageIndex'
+	 */
+	indexId?: string;
 }
 
 /**
@@ -57,7 +192,9 @@ export interface IConfigNode {
 	id: string;
 
 	/**
-	 * CSS selector
+	 * CSS selector, or XPath expression prefixed with 'xpath:', such as "xpath:.//td[.='Name']".
+	 * Both may follow the :frame() and :shadow() pseudos.
+	 * A css selector may have the :contains(text), :matches(regex), :label(text), :next, :parent and :deep pseudos
 	 */
 	selector: string;
 
@@ -107,6 +244,43 @@ export interface IDataSection extends IConfigNode {
 	 * Function signature: It takes two fixed parameters: val, node, and this refers to the current item.
 	 */
 	dataRender?: string;
+
+	/**
+	 * Pagination of this section, optional. Only applies to list sections at the root of dataSection.
+	 */
+	pagination?: IPagination;
+
+	/**
+	 * Scroll-driven harvesting of a list section whose rows are rendered while scrolling, optional.
+	 * Only applies to list sections at the root of dataSection.
+	 */
+	scroll?: IScroll;
+}
+
+/**
+ * Scroll configuration for lazy-load lists, executed by the Go side.
+ * The page or the container is scrolled until the row count stops growing, maxRows is reached or timeout hits.
+ */
+export interface IScroll {
+	/**
+	 * CSS selector of the scrolling element, the page is scrolled if empty
+	 */
+	container?: string;
+
+	/**
+	 * Stops scrolling when the list has this many rows, no limit if 0
+	 */
+	maxRows?: number;
+
+	/**
+	 * Timeout in seconds, defaults to 60
+	 */
+	timeout?: number;
+
+	/**
+	 * Id of the item used to dedupe rows, the whole row is compared if empty
+	 */
+	keyId?: string;
 }
 
 /**
@@ -186,6 +360,11 @@ export interface ICaseItem {
 	 * Array of data sections and value items
 	 */
 	dataSection: (IDataSection | IValueItem)[];
+
+	/**
+	 * Steps run when the case matches, before its dataSection is crawled
+	 */
+	actions?: IAction[];
 }
 
 /**
@@ -269,6 +448,41 @@ export interface IResult {
 	 * Parsed external section
 	 */
 	externalSection?: Record<string, IExternal>;
+
+	/**
+	 * Errors occurred while crawling
+	 */
+	errors?: ICrawlError[];
+
+	/**
+	 * Index of the case of the switchSection matched, -1 if none matched
+	 */
+	matchedCase?: number;
+}
+
+/**
+ * Error entry of IResult
+ */
+export interface ICrawlError {
+	/**
+	 * Slash separated path of the failed node in the result, such as 'list/0/title'
+	 */
+	path: string;
+
+	/**
+	 * Where the error occurred, such as 'valueRender', 'filterRender', 'dataRender', 'nameRender', 'linkRender', 'frame', 'action', 'fill'
+	 */
+	stage: string;
+
+	/**
+	 * CSS selector of the failed node
+	 */
+	selector: string;
+
+	/**
+	 * Error message
+	 */
+	message: string;
 }
 
 /**
@@ -0,0 +1,161 @@
+// Package rpatest runs crawler configs against local fixtures, so the tests of a config don't hit the network.
+//
+// A fixture directory holds the pages and the files they refer to, it is served by an httptest.Server.
+// The files under its "attachments" directory are served with a "Content-Disposition: attachment" header,
+// so the links to them are downloads.
+//
+// The results are compared with golden JSON files, run the tests with the -update flag to rewrite them:
+//
+//	go test ./... -update
+package rpatest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	rpa "github.com/rpdg/rod-helper"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of rpatest")
+
+// Placeholders of the golden files, the server url and the download dir change with each run
+const (
+	ServerPlaceholder    = "{{server}}"
+	DownloadsPlaceholder = "{{downloads}}"
+)
+
+// Harness serves a fixture directory and crawls its pages with a headless browser
+type Harness struct {
+	T       testing.TB
+	Server  *httptest.Server
+	Crawler *rpa.Crawler
+	// DownloadDir is the download root of the crawls, a temp dir removed with the test
+	DownloadDir string
+}
+
+// New serves dir and attaches the embedded browser, the test is skipped if the browser can't be launched.
+// Both are closed with the test.
+func New(t testing.TB, dir string) *Harness {
+	t.Helper()
+	srv := NewServer(t, dir)
+	return &Harness{
+		T:           t,
+		Server:      srv,
+		Crawler:     &rpa.Crawler{Browser: Browser(t)},
+		DownloadDir: t.TempDir(),
+	}
+}
+
+// NewServer serves the fixture directory dir until the test ends
+func NewServer(t testing.TB, dir string) *httptest.Server {
+	files := http.FileServer(http.Dir(dir))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := path.Clean(r.URL.Path); strings.HasPrefix(p, "/attachments/") {
+			w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(path.Base(p)))
+		}
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// Browser launches the embedded browser headless, the test is skipped if it can't be launched
+func Browser(t testing.TB) *rod.Browser {
+	t.Helper()
+	br, err := rpa.ConnectChromiumBrowser(true, true)
+	if err != nil {
+		t.Skipf("no browser: %v", err)
+	}
+	t.Cleanup(func() { _ = br.Close() })
+	return br
+}
+
+// URL returns the url of the fixture file p
+func (h *Harness) URL(p string) string {
+	return h.Server.URL + "/" + strings.TrimPrefix(p, "/")
+}
+
+// Page opens the fixture file p in a new tab, closed with the test
+func (h *Harness) Page(p string) *rod.Page {
+	h.T.Helper()
+	page, err := h.Crawler.Browser.Page(proto.TargetCreateTarget{URL: h.URL(p)})
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	h.T.Cleanup(func() { _ = page.Close() })
+	return page
+}
+
+// Crawl crawls the fixture file p with cfgOrFile, the tab is closed after the crawl.
+// The files are downloaded into DownloadDir unless opts.DownloadRoot is set.
+func (h *Harness) Crawl(p string, cfgOrFile interface{}, opts rpa.CrawlOptions) (*rpa.Result, error) {
+	opts.CloseTab = true
+	if opts.DownloadRoot == "" {
+		opts.DownloadRoot = h.DownloadDir
+	}
+	if opts.DownloadTempDir == "" {
+		opts.DownloadTempDir = h.T.TempDir()
+	}
+	res, _, err := h.Crawler.CrawlUrlWithOptions(context.Background(), h.URL(p), cfgOrFile, opts)
+	return res, err
+}
+
+// Golden compares res with the golden file, or rewrites the file with the -update flag.
+// The server url and DownloadDir are replaced by ServerPlaceholder and DownloadsPlaceholder in the file.
+func (h *Harness) Golden(file string, res *rpa.Result) {
+	h.T.Helper()
+	got, err := h.normalize(res)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(file), os.ModePerm); err == nil {
+			err = os.WriteFile(file, got, 0644)
+		}
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		h.T.Fatalf("%v, run the test with -update to create it", err)
+	}
+	var a, b interface{}
+	if err = json.Unmarshal(want, &a); err != nil {
+		h.T.Fatalf("invalid golden file %s: %v", file, err)
+	}
+	_ = json.Unmarshal(got, &b)
+	if !reflect.DeepEqual(a, b) {
+		h.T.Errorf("result differs from %s\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+// normalize returns the indented JSON of res with the placeholders
+func (h *Harness) normalize(res *rpa.Result) ([]byte, error) {
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	for val, placeholder := range map[string]string{h.Server.URL: ServerPlaceholder, h.DownloadDir: DownloadsPlaceholder} {
+		// the value as it's escaped in JSON, such as the backslashes of a windows path
+		escaped, _ := json.Marshal(val)
+		b = bytes.ReplaceAll(b, escaped[1:len(escaped)-1], []byte(placeholder))
+	}
+	return append(b, '\n'), nil
+}
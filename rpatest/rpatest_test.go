@@ -0,0 +1,49 @@
+package rpatest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rpa "github.com/rpdg/rod-helper"
+)
+
+func TestNewServer(t *testing.T) {
+	srv := NewServer(t, "../testdata/site")
+
+	cases := []struct {
+		path, disposition string
+	}{
+		{"/downloads.html", ""},
+		{"/attachments/report.txt", `attachment; filename="report.txt"`},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Disposition") != c.disposition {
+			t.Errorf("%s: unexpected response %d %q", c.path, resp.StatusCode, resp.Header.Get("Content-Disposition"))
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	h := &Harness{
+		Server:      &httptest.Server{URL: "http://127.0.0.1:8080"},
+		DownloadDir: `C:\tmp\dl`,
+	}
+	b, err := h.normalize(&rpa.Result{
+		Data:         rpa.DictData{"link": "http://127.0.0.1:8080/a.html"},
+		DownloadRoot: `C:\tmp\dl`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if !strings.Contains(s, `"{{server}}/a.html"`) || !strings.Contains(s, `"downloadRoot": "{{downloads}}"`) {
+		t.Errorf("unexpected normalized result:\n%s", s)
+	}
+}
@@ -0,0 +1,191 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// ScrollLoad describes how to harvest a list section whose rows are rendered while scrolling.
+//
+// The page or the container is scrolled to the bottom until the row count stops growing,
+// MaxRows is reached or Timeout hits.
+type ScrollLoad struct {
+	// Container is the selector of the scrolling element, the page is scrolled if empty
+	Container string `json:"container,omitempty"`
+
+	// MaxRows stops scrolling when the list has this many rows, no limit if zero
+	MaxRows int `json:"maxRows,omitempty"`
+
+	// Timeout in seconds to stop scrolling, defaults to 60
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// KeyID is the id of the item used to dedupe rows, the whole row is compared if empty
+	KeyID string `json:"keyId,omitempty"`
+}
+
+// idle scroll rounds without new rows before the list is considered complete
+const scrollIdleRounds = 2
+
+// scrollSections harvests the list sections having a scroll load, the rows in result.Data are replaced
+// and the errors of the rows are re-indexed to the harvested rows.
+func scrollSections(ctx context.Context, page *rod.Page, sections DataNodes, result *Result, opts CrawlOptions) error {
+	for _, node := range sections {
+		sec, ok := node.(*DataSection)
+		if !ok || sec.Scroll == nil {
+			continue
+		}
+		id := sec.ID
+		first, _ := result.Data[id].([]interface{})
+		var firstErrs []CrawlError
+		result.Errors, firstErrs = splitRowErrors(result.Errors, id)
+		rows, rowErrs, err := scrollHarvest(ctx, page, sec, first, firstErrs, opts)
+		result.Errors = append(result.Errors, rowErrs...)
+		if err != nil {
+			return err
+		}
+		result.Data[id] = rows
+	}
+	return nil
+}
+
+// scrollHarvest scrolls and crawls the section repeatedly, collecting the deduped rows and their errors
+func scrollHarvest(ctx context.Context, page *rod.Page, sec *DataSection, first []interface{}, firstErrs []CrawlError, opts CrawlOptions) ([]interface{}, []CrawlError, error) {
+	sl := sec.Scroll
+	timeout := time.Duration(sl.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	tCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	id := sec.ID
+	rows := make([]interface{}, 0, len(first))
+	var rowErrs []CrawlError
+	seen := make(map[string]bool)
+	add := func(list []interface{}, errs []CrawlError) (added int) {
+		moved := make(map[int]int)
+		for i, row := range list {
+			key := rowKey(row, sl.KeyID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			moved[i] = len(rows)
+			rows = append(rows, row)
+			added++
+		}
+		rowErrs = append(rowErrs, moveRowErrors(errs, id, func(i int) (int, bool) {
+			n, ok := moved[i]
+			return n, ok
+		})...)
+		return
+	}
+	add(first, firstErrs)
+	limit := func() ([]interface{}, []CrawlError) {
+		if sl.MaxRows <= 0 || len(rows) <= sl.MaxRows {
+			return rows, rowErrs
+		}
+		return rows[:sl.MaxRows], moveRowErrors(rowErrs, id, func(i int) (int, bool) {
+			return i, i < sl.MaxRows
+		})
+	}
+
+	p := page.Context(tCtx)
+	subCfg := &CrawlerConfig{DataSection: DataNodes{sec}}
+	for idle := 0; idle < scrollIdleRounds; {
+		if sl.MaxRows > 0 && len(rows) >= sl.MaxRows {
+			rows, rowErrs := limit()
+			return rows, rowErrs, nil
+		}
+
+		_, err := p.Eval(fmt.Sprintf(`(selector) => {
+			%s
+			const el = selector ? queryElem(selector) : (document.scrollingElement || document.documentElement);
+			if (el) el.scrollTop = el.scrollHeight;
+		}`, commonJSCode), sl.Container)
+		if err == nil {
+			err = p.WaitStable(opts.StableDuration)
+		}
+		var res *Result
+		if err == nil {
+			res, err = extract(p, subCfg, opts.Engine)
+		}
+		if err != nil {
+			if ctx.Err() == nil && tCtx.Err() != nil {
+				// timeout is a stop condition, keep what has been harvested
+				break
+			}
+			return rows, rowErrs, err
+		}
+
+		list, _ := res.Data[id].([]interface{})
+		_, errs := splitRowErrors(res.Errors, id)
+		if add(list, errs) == 0 {
+			idle++
+		} else {
+			idle = 0
+		}
+	}
+
+	rows, rowErrs = limit()
+	return rows, rowErrs, nil
+}
+
+// rowKey returns the dedupe key of a list row
+func rowKey(row interface{}, keyID string) string {
+	if r, ok := row.(map[string]interface{}); ok && keyID != "" {
+		if v, has := r[keyID]; has {
+			return fmt.Sprint(v)
+		}
+	}
+	b, _ := json.Marshal(row)
+	return string(b)
+}
+
+// splitRowErrors separates the errors under the section id from the others
+func splitRowErrors(errs []CrawlError, id string) (others, rowErrs []CrawlError) {
+	for _, e := range errs {
+		if e.Path == id || strings.HasPrefix(e.Path, id+"/") {
+			rowErrs = append(rowErrs, e)
+		} else {
+			others = append(others, e)
+		}
+	}
+	return
+}
+
+// moveRowErrors re-indexes the errors of the rows of the list section id, such as "list/3/title",
+// move returns the new index of a row, or false to drop the errors of the row.
+// The errors not belonging to a row are kept as they are.
+func moveRowErrors(errs []CrawlError, id string, move func(i int) (int, bool)) []CrawlError {
+	var moved []CrawlError
+	prefix := id + "/"
+	for _, e := range errs {
+		if !strings.HasPrefix(e.Path, prefix) {
+			moved = append(moved, e)
+			continue
+		}
+		index, rest, _ := strings.Cut(strings.TrimPrefix(e.Path, prefix), "/")
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			moved = append(moved, e)
+			continue
+		}
+		n, ok := move(i)
+		if !ok {
+			continue
+		}
+		e.Path = prefix + strconv.Itoa(n)
+		if rest != "" {
+			e.Path += "/" + rest
+		}
+		moved = append(moved, e)
+	}
+	return moved
+}
@@ -0,0 +1,58 @@
+package rpa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_rowKey(t *testing.T) {
+	row := map[string]interface{}{"id": "42", "title": "a"}
+	if k := rowKey(row, "id"); k != "42" {
+		t.Errorf("expected key 42, got %q", k)
+	}
+	if k := rowKey(row, ""); k != `{"id":"42","title":"a"}` {
+		t.Errorf("unexpected whole row key %q", k)
+	}
+	if k := rowKey(row, "missing"); k != `{"id":"42","title":"a"}` {
+		t.Errorf("unexpected fallback key %q", k)
+	}
+}
+
+func Test_sectionScroll(t *testing.T) {
+	var sec DataSection
+	err := json.Unmarshal([]byte(`{"id": "rows", "selector": ".row", "sectionType": "list", "items": [],
+		"scroll": {"container": ".grid", "maxRows": 100, "keyId": "id"}}`), &sec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl := sec.Scroll
+	if sl == nil || sl.Container != ".grid" || sl.MaxRows != 100 || sl.KeyID != "id" {
+		t.Errorf("unexpected scroll %+v", sl)
+	}
+}
+
+func Test_moveRowErrors(t *testing.T) {
+	errs := []CrawlError{
+		{Path: "list/0/title", Stage: StageValueRender},
+		{Path: "list/1", Stage: StageFilterRender},
+		{Path: "list/2/files/0", Stage: StageLinkRender},
+		{Path: "form/price", Stage: StageValueRender},
+	}
+	moved := moveRowErrors(errs, "list", func(i int) (int, bool) {
+		return i + 10, i != 1
+	})
+	expected := []string{"list/10/title", "list/12/files/0", "form/price"}
+	if len(moved) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, moved)
+	}
+	for i, e := range moved {
+		if e.Path != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], e.Path)
+		}
+	}
+
+	others, rowErrs := splitRowErrors(errs, "list")
+	if len(others) != 1 || len(rowErrs) != 3 {
+		t.Errorf("unexpected split %v %v", others, rowErrs)
+	}
+}
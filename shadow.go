@@ -0,0 +1,158 @@
+package rpa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// shadowMapJS maps the hosts to their closed shadow roots, nodes are the hosts and the roots in turn.
+// The map is only handed to the scripts of the crawler as an argument, the page scripts can't reach it.
+const shadowMapJS = `function (...nodes) {
+	const roots = new WeakMap();
+	for (let i = 0; i + 1 < nodes.length; i += 2) {
+		roots.set(nodes[i], nodes[i + 1]);
+	}
+	return roots;
+}`
+
+// usesShadow reports whether the selector, or the json of a config, reaches into shadow roots
+func usesShadow(selector string) bool {
+	return strings.Contains(selector, ":shadow(") || strings.Contains(selector, ":deep")
+}
+
+// shadowRootsFor returns the closed shadow roots for an extraction of cfg, nil if cfg doesn't reach into shadow roots
+func shadowRootsFor(cfg *CrawlerConfig) (*shadowRoots, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil || !usesShadow(string(b)) {
+		return nil, err
+	}
+	return newShadowRoots(), nil
+}
+
+// shadowGroups numbers the object groups of the shadowRoots
+var shadowGroups atomic.Int64
+
+// shadowRoots hands the closed shadow roots of the frames of a page to the page script.
+// The script can't read a closed shadow root from its host, the DOM domain pierces it.
+//
+// The roots of a frame are read once on its first use, and kept until release,
+// so an extraction walks the DOM once rather than once per selector.
+// A nil shadowRoots hands no roots.
+type shadowRoots struct {
+	group string
+	// the WeakMap of every frame used, nil if the frame has no closed shadow root
+	maps  map[proto.PageFrameID]*proto.RuntimeRemoteObject
+	pages []*rod.Page
+}
+
+func newShadowRoots() *shadowRoots {
+	return &shadowRoots{
+		group: fmt.Sprintf("rpaShadowRoots%d", shadowGroups.Add(1)),
+		maps:  make(map[proto.PageFrameID]*proto.RuntimeRemoteObject),
+	}
+}
+
+// arg returns the argument of a script run in page, the WeakMap of the closed shadow roots of its frame or null
+func (s *shadowRoots) arg(page *rod.Page) (interface{}, error) {
+	if s == nil {
+		return nil, nil
+	}
+	roots, ok := s.maps[page.FrameID]
+	if !ok {
+		var err error
+		if roots, err = exposeClosedShadowRoots(page, s.group); err != nil {
+			return nil, err
+		}
+		s.maps[page.FrameID] = roots
+		// released even after the context of page is done
+		s.pages = append(s.pages, page.Context(context.Background()))
+	}
+	if roots == nil {
+		return nil, nil
+	}
+	return roots, nil
+}
+
+// release releases the nodes resolved for the roots so the handles don't pile up in the pages,
+// the roots are read again on their next use
+func (s *shadowRoots) release() {
+	if s == nil {
+		return
+	}
+	for _, page := range s.pages {
+		_ = proto.RuntimeReleaseObjectGroup{ObjectGroup: s.group}.Call(page)
+	}
+	s.maps = make(map[proto.PageFrameID]*proto.RuntimeRemoteObject)
+	s.pages = nil
+}
+
+// exposeClosedShadowRoots returns a WeakMap of the closed shadow roots of the document of page by their hosts,
+// nil if there's none. The map and the nodes are in the object group.
+// The roots in the frames of the document are left to the pages of the frames, they run their own scripts.
+func exposeClosedShadowRoots(page *rod.Page, group string) (*proto.RuntimeRemoteObject, error) {
+	doc, err := page.Evaluate(rod.Eval(`() => document`).ByObject())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = proto.RuntimeReleaseObject{ObjectID: doc.ObjectID}.Call(page)
+	}()
+
+	depth := -1
+	node, err := proto.DOMDescribeNode{ObjectID: doc.ObjectID, Depth: &depth, Pierce: true}.Call(page)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs []proto.DOMBackendNodeID
+	var walk func(node *proto.DOMNode)
+	walk = func(node *proto.DOMNode) {
+		for _, root := range node.ShadowRoots {
+			if root.ShadowRootType == proto.DOMShadowRootTypeClosed {
+				pairs = append(pairs, node.BackendNodeID, root.BackendNodeID)
+			}
+			walk(root)
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(node.Node)
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	args := make([]*proto.RuntimeCallArgument, 0, len(pairs))
+	for i := 0; i < len(pairs); i += 2 {
+		host, err := proto.DOMResolveNode{BackendNodeID: pairs[i], ObjectGroup: group}.Call(page)
+		if err != nil {
+			// the host has been removed since the document was read
+			continue
+		}
+		root, err := proto.DOMResolveNode{BackendNodeID: pairs[i+1], ObjectGroup: group}.Call(page)
+		if err != nil {
+			continue
+		}
+		args = append(args, &proto.RuntimeCallArgument{ObjectID: host.Object.ObjectID}, &proto.RuntimeCallArgument{ObjectID: root.Object.ObjectID})
+	}
+
+	res, err := proto.RuntimeCallFunctionOn{
+		ObjectID:            doc.ObjectID,
+		FunctionDeclaration: shadowMapJS,
+		Arguments:           args,
+		ObjectGroup:         group,
+	}.Call(page)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExceptionDetails != nil {
+		return nil, &rod.EvalError{RuntimeExceptionDetails: res.ExceptionDetails}
+	}
+	return res.Result, nil
+}
@@ -0,0 +1,30 @@
+package rpa
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_shadowRootsFor(t *testing.T) {
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(`{"dataSection": [{"id": "title", "selector": "h1", "itemType": "text"}]}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := shadowRootsFor(&cfg)
+	if err != nil || roots != nil {
+		t.Fatalf("expected no shadow roots, got %v, %v", roots, err)
+	}
+	// a nil shadowRoots hands null to the scripts
+	if arg, err := roots.arg(nil); arg != nil || err != nil {
+		t.Errorf("expected a nil argument, got %v, %v", arg, err)
+	}
+	roots.release()
+
+	cfg.DataSection[0].Node().Selector = ":shadow(#card) .price"
+	if roots, err = shadowRootsFor(&cfg); err != nil || roots == nil {
+		t.Fatalf("expected shadow roots, got %v, %v", roots, err)
+	}
+	if other := newShadowRoots(); other.group == roots.group {
+		t.Errorf("expected an object group per shadowRoots, got %s twice", roots.group)
+	}
+}
@@ -0,0 +1,404 @@
+package rpa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// StateVersion is the version of the state files written by ExportState
+const StateVersion = 1
+
+// stateCipher is the cipher of the encrypted state files
+const stateCipher = "aes-256-gcm"
+
+// BrowserState is the content of a state file, the cookies and the web storage of a browser:
+//
+//	{
+//	  "version": 1,
+//	  "cookies": [
+//	    {"name": "sid", "value": "...", "domain": ".example.com", "path": "/", "expires": 1767225600, "httpOnly": true, "secure": true, ...}
+//	  ],
+//	  "origins": [
+//	    {"origin": "https://example.com", "localStorage": {"token": "..."}, "sessionStorage": {"step": "2"}}
+//	  ]
+//	}
+//
+// The cookies have the fields of the Network.Cookie type of the Chrome DevTools Protocol.
+//
+// An encrypted state file holds the JSON of the BrowserState encrypted with AES-256-GCM,
+// the key is the SHA-256 hash of the key of the caller:
+//
+//	{"version": 1, "cipher": "aes-256-gcm", "nonce": "<base64>", "data": "<base64>"}
+type BrowserState struct {
+	Version int                    `json:"version"`
+	Cookies []*proto.NetworkCookie `json:"cookies"`
+	Origins []OriginState          `json:"origins"`
+}
+
+// OriginState is the web storage of an origin, such as "https://example.com"
+type OriginState struct {
+	Origin         string            `json:"origin"`
+	LocalStorage   map[string]string `json:"localStorage,omitempty"`
+	SessionStorage map[string]string `json:"sessionStorage,omitempty"`
+}
+
+// encryptedState is the content of an encrypted state file
+type encryptedState struct {
+	Version int    `json:"version"`
+	Cipher  string `json:"cipher"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// StateOptions selects the state exported by ExportState and imported by ImportState
+type StateOptions struct {
+	// Domains are the domains of the cookies, such as "example.com" for the cookies of example.com and its subdomains.
+	// All the cookies if empty.
+	Domains []string
+
+	// Origins are the origins of the web storage, such as "https://example.com".
+	// No web storage is exported if empty, and all the origins of the file are imported.
+	Origins []string
+
+	// Key encrypts the state file, it's not encrypted if empty
+	Key []byte
+}
+
+// ExportState writes the cookies and the web storage of the browser to the file at path, see BrowserState for its format.
+//
+// The localStorage of an origin is read in a blank tab of the origin, the origin is not loaded.
+// The sessionStorage is read from the first open tab of the origin, as it belongs to the tab.
+func (c *Crawler) ExportState(path string, opts StateOptions) error {
+	state, err := exportState(c.Browser, opts)
+	if err != nil {
+		return err
+	}
+	return writeState(path, state, opts.Key)
+}
+
+// ImportState restores the cookies and the web storage of the state file at path into the browser,
+// the cookies and the origins not selected by opts are skipped.
+//
+// As the sessionStorage belongs to a tab, it's set in the tabs opened by the following CrawlUrl calls of the crawler,
+// before the scripts of their pages run.
+func (c *Crawler) ImportState(path string, opts StateOptions) error {
+	state, err := readState(path, opts.Key)
+	if err != nil {
+		return err
+	}
+	sessions, err := importState(c.Browser, state, opts)
+	if err != nil {
+		return err
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.sessionStorage == nil {
+		c.sessionStorage = make(map[string]map[string]string)
+	}
+	for origin, items := range sessions {
+		c.sessionStorage[origin] = items
+	}
+	return nil
+}
+
+// exportState reads the state of the browser selected by opts
+func exportState(browser *rod.Browser, opts StateOptions) (*BrowserState, error) {
+	cookies, err := browser.GetCookies()
+	if err != nil {
+		return nil, err
+	}
+	state := &BrowserState{Version: StateVersion, Cookies: make([]*proto.NetworkCookie, 0), Origins: make([]OriginState, 0)}
+	for _, ck := range cookies {
+		if cookieSelected(ck.Domain, opts.Domains) {
+			state.Cookies = append(state.Cookies, ck)
+		}
+	}
+
+	for _, o := range opts.Origins {
+		origin, err := normalizeOrigin(o)
+		if err != nil {
+			return nil, err
+		}
+		st := OriginState{Origin: origin}
+		err = withOriginPage(browser, origin, func(page *rod.Page) error {
+			st.LocalStorage, err = storageItems(page, "localStorage")
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("localStorage of %s: %w", origin, err)
+		}
+		if page := originTab(browser, origin); page != nil {
+			if st.SessionStorage, err = storageItems(page, "sessionStorage"); err != nil {
+				return nil, fmt.Errorf("sessionStorage of %s: %w", origin, err)
+			}
+		}
+		state.Origins = append(state.Origins, st)
+	}
+	return state, nil
+}
+
+// importState restores the cookies and the localStorage of state selected by opts into the browser,
+// and returns the sessionStorage by origin
+func importState(browser *rod.Browser, state *BrowserState, opts StateOptions) (map[string]map[string]string, error) {
+	var cookies []*proto.NetworkCookie
+	for _, ck := range state.Cookies {
+		if cookieSelected(ck.Domain, opts.Domains) {
+			cookies = append(cookies, ck)
+		}
+	}
+	// SetCookies clears the cookies if there are none
+	if len(cookies) > 0 {
+		if err := browser.SetCookies(cookieParams(cookies)); err != nil {
+			return nil, err
+		}
+	}
+
+	origins := make(map[string]bool)
+	for _, o := range opts.Origins {
+		origin, err := normalizeOrigin(o)
+		if err != nil {
+			return nil, err
+		}
+		origins[origin] = true
+	}
+
+	sessions := make(map[string]map[string]string)
+	for _, st := range state.Origins {
+		if len(origins) > 0 && !origins[st.Origin] {
+			continue
+		}
+		if len(st.LocalStorage) > 0 {
+			err := withOriginPage(browser, st.Origin, func(page *rod.Page) error {
+				_, err := page.Eval(`(items) => Object.keys(items).forEach((k) => localStorage.setItem(k, items[k]))`, st.LocalStorage)
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("localStorage of %s: %w", st.Origin, err)
+			}
+		}
+		if len(st.SessionStorage) > 0 {
+			sessions[st.Origin] = st.SessionStorage
+		}
+	}
+	return sessions, nil
+}
+
+// cookieSelected reports whether a cookie of domain is of one of the domains, any domain if there are none
+func cookieSelected(domain string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(d), ".")
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOrigin returns the scheme, host and port of the url o
+func normalizeOrigin(o string) (string, error) {
+	u, err := url.Parse(o)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid origin %q", o)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// withOriginPage runs fn in a blank tab of the origin, closed afterwards.
+// The requests of the tab are answered with an empty document, so the pages of the origin don't load.
+func withOriginPage(browser *rod.Browser, origin string, fn func(page *rod.Page) error) error {
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = page.Close() }()
+
+	router := page.HijackRequests()
+	err = router.Add("*", "", func(h *rod.Hijack) {
+		h.Response.SetHeader("Content-Type", "text/html; charset=utf-8").SetBody("<!DOCTYPE html><html></html>")
+	})
+	if err != nil {
+		return err
+	}
+	go router.Run()
+	defer func() { _ = router.Stop() }()
+
+	if err = page.Navigate(origin + "/"); err != nil {
+		return err
+	}
+	if err = page.WaitLoad(); err != nil {
+		return err
+	}
+	return fn(page)
+}
+
+// originTab returns the first open tab of the origin, nil if there isn't one
+func originTab(browser *rod.Browser, origin string) *rod.Page {
+	pages, err := browser.Pages()
+	if err != nil {
+		return nil
+	}
+	for _, p := range pages {
+		info, err := p.Info()
+		if err != nil {
+			continue
+		}
+		if o, err := normalizeOrigin(info.URL); err == nil && o == origin {
+			return p
+		}
+	}
+	return nil
+}
+
+func storageItems(page *rod.Page, storage string) (map[string]string, error) {
+	res, err := page.Eval(`(storage) => {
+		const s = window[storage], items = {};
+		for (let i = 0; i < s.length; i++) {
+			items[s.key(i)] = s.getItem(s.key(i));
+		}
+		return items;
+	}`, storage)
+	if err != nil {
+		return nil, err
+	}
+	var items map[string]string
+	err = res.Value.Unmarshal(&items)
+	return items, err
+}
+
+// sessionStorageJS sets the sessionStorage of the origin of the page before its scripts run, the items already set are kept
+const sessionStorageJS = `(function (sessions) {
+	const items = sessions[location.origin];
+	if (!items) {
+		return;
+	}
+	try {
+		Object.keys(items).forEach((k) => {
+			if (sessionStorage.getItem(k) === null) {
+				sessionStorage.setItem(k, items[k]);
+			}
+		});
+	} catch (e) {}
+})(%s)`
+
+// openPage opens the url in a new tab of browser, with the imported sessionStorage of the crawler set in the tab
+func (c *Crawler) openPage(browser *rod.Browser, url string) (*rod.Page, error) {
+	c.stateMu.Lock()
+	sessions, err := json.Marshal(c.sessionStorage)
+	n := len(c.sessionStorage)
+	c.stateMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return browser.Page(proto.TargetCreateTarget{URL: url})
+	}
+
+	page, err := browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = page.EvalOnNewDocument(fmt.Sprintf(sessionStorageJS, sessions)); err == nil {
+		err = page.Navigate(url)
+	}
+	if err != nil {
+		_ = page.Close()
+		return nil, err
+	}
+	return page, nil
+}
+
+// writeState writes state to path, encrypted with key if it's not empty
+func writeState(path string, state *BrowserState, key []byte) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if len(key) > 0 {
+		gcm, err := stateGCM(key)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, gcm.NonceSize())
+		if _, err = rand.Read(nonce); err != nil {
+			return err
+		}
+		b, err = json.MarshalIndent(encryptedState{
+			Version: StateVersion,
+			Cipher:  stateCipher,
+			Nonce:   nonce,
+			Data:    gcm.Seal(nil, nonce, b, nil),
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	// the cookies are credentials, the file is readable by the current user only
+	return os.WriteFile(path, b, 0600)
+}
+
+// readState reads the state file at path, decrypted with key if it's encrypted
+func readState(path string, key []byte) (*BrowserState, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var enc encryptedState
+	if err = json.Unmarshal(b, &enc); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if enc.Version > StateVersion {
+		return nil, fmt.Errorf("state file %s has the unknown version %d", path, enc.Version)
+	}
+	if enc.Cipher != "" {
+		if enc.Cipher != stateCipher {
+			return nil, fmt.Errorf("state file %s has the unknown cipher %q", path, enc.Cipher)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("state file %s is encrypted, a key is required", path)
+		}
+		gcm, err := stateGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(enc.Nonce) != gcm.NonceSize() {
+			return nil, fmt.Errorf("invalid state file %s: bad nonce", path)
+		}
+		if b, err = gcm.Open(nil, enc.Nonce, enc.Data, nil); err != nil {
+			return nil, errors.New("can't decrypt the state file " + path + ", wrong key")
+		}
+	}
+
+	var state BrowserState
+	if err = json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func stateGCM(key []byte) (cipher.AEAD, error) {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
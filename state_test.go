@@ -0,0 +1,90 @@
+package rpa
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-rod/rod/lib/proto"
+)
+
+func Test_writeReadState(t *testing.T) {
+	state := &BrowserState{
+		Version: StateVersion,
+		Cookies: []*proto.NetworkCookie{{Name: "sid", Value: "abc", Domain: ".example.com", Path: "/"}},
+		Origins: []OriginState{{Origin: "https://example.com", LocalStorage: map[string]string{"token": "xyz"}}},
+	}
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "plain.json")
+	if err := writeState(plain, state, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readState(plain, nil)
+	if err != nil || !reflect.DeepEqual(got, state) {
+		t.Errorf("expected %+v, got %+v, %v", state, got, err)
+	}
+
+	key := []byte("correct horse battery staple")
+	sealed := filepath.Join(dir, "sealed.json")
+	if err = writeState(sealed, state, key); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(sealed)
+	if strings.Contains(string(b), "xyz") || !strings.Contains(string(b), stateCipher) {
+		t.Errorf("state not encrypted: %s", b)
+	}
+	got, err = readState(sealed, key)
+	if err != nil || !reflect.DeepEqual(got, state) {
+		t.Errorf("expected %+v, got %+v, %v", state, got, err)
+	}
+	if _, err = readState(sealed, []byte("wrong")); err == nil {
+		t.Error("expected an error with a wrong key")
+	}
+	if _, err = readState(sealed, nil); err == nil {
+		t.Error("expected an error without a key")
+	}
+}
+
+func Test_cookieSelected(t *testing.T) {
+	domains := []string{"example.com"}
+	for domain, want := range map[string]bool{
+		"example.com":      true,
+		".example.com":     true,
+		"www.Example.com":  true,
+		"badexample.com":   false,
+		"example.com.evil": false,
+		"other.org":        false,
+	} {
+		if got := cookieSelected(domain, domains); got != want {
+			t.Errorf("cookieSelected(%q) = %v, want %v", domain, got, want)
+		}
+	}
+	if !cookieSelected("other.org", nil) {
+		t.Error("expected all the cookies without domains")
+	}
+}
+
+func Test_cookieParams(t *testing.T) {
+	params := cookieParams([]*proto.NetworkCookie{
+		{Name: "sid", Expires: 1700000000},
+		{Name: "lang", Expires: -1, Session: true},
+	})
+	if params[0].Expires != 1700000000 {
+		t.Errorf("expected the expiry of a persistent cookie kept, got %v", params[0].Expires)
+	}
+	if params[1].Expires != 0 {
+		t.Errorf("expected no expiry of a session cookie, got %v", params[1].Expires)
+	}
+}
+
+func Test_normalizeOrigin(t *testing.T) {
+	if o, err := normalizeOrigin("HTTPS://Example.com:8443/path?q=1"); err != nil || o != "https://example.com:8443" {
+		t.Errorf("unexpected origin %q, %v", o, err)
+	}
+	if _, err := normalizeOrigin("example.com"); err == nil {
+		t.Error("expected an error for a url without scheme")
+	}
+}
@@ -0,0 +1,322 @@
+package rpa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The Go model of the dataSection of a config, mirrors resource/types.d.ts.
+//
+// The keys not known by the model are kept in the Extra of each node, and of the config,
+// its pageLoad and its downloadSection, so a config decoded and encoded again keeps all of its content.
+
+type ItemTypeString string
+
+const (
+	ItemText     ItemTypeString = "text"
+	ItemTextBox  ItemTypeString = "textBox"
+	ItemRadioBox ItemTypeString = "radioBox"
+	ItemCheckBox ItemTypeString = "checkBox"
+	ItemDropBox  ItemTypeString = "dropBox"
+	ItemDownload ItemTypeString = "download"
+)
+
+type SectionTypeString string
+
+const (
+	SectionForm SectionTypeString = "form"
+	SectionList SectionTypeString = "list"
+)
+
+// DataNode is a node of a dataSection, either a *ValueItem or a *DataSection
+type DataNode interface {
+	// Node returns the selector, label and id of the node
+	Node() *ConfigNode
+	dataNode()
+}
+
+// ValueItem is an item crawling a single value, IValueItem of types.d.ts
+type ValueItem struct {
+	ConfigNode
+	ItemType    ItemTypeString `json:"itemType,omitempty"`
+	ValueProper string         `json:"valueProper,omitempty"`
+	ValueRender string         `json:"valueRender,omitempty"`
+	DownloadID  string         `json:"downloadId,omitempty"`
+	External    *ExternalItem  `json:"external,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// DataSection is a form or a list of items and sections, IDataSection of types.d.ts
+type DataSection struct {
+	ConfigNode
+	SectionType  SectionTypeString `json:"sectionType"`
+	Items        DataNodes         `json:"items"`
+	FilterRender string            `json:"filterRender,omitempty"`
+	DataRender   string            `json:"dataRender,omitempty"`
+	Pagination   *Pagination       `json:"pagination,omitempty"`
+	Scroll       *ScrollLoad       `json:"scroll,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// ExternalItem is the external link setting of a ValueItem
+type ExternalItem struct {
+	Config ExternalConfig `json:"config"`
+	ID     string         `json:"id,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// SwitchSection crawls the dataSection of the case matching the value returned by SwitchRender
+type SwitchSection struct {
+	// SwitchRender is a JavaScript function body taking 2 fixed parameters: data, config
+	SwitchRender string     `json:"switchRender"`
+	Cases        []CaseItem `json:"cases"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// CaseItem is a case of a SwitchSection
+type CaseItem struct {
+	// Case is a string, number, boolean or null, or an array of strings or numbers matching any of them
+	Case        interface{} `json:"case"`
+	DataSection DataNodes   `json:"dataSection"`
+	// Actions run when the case is matched, before its dataSection is crawled
+	Actions []Action `json:"actions,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+func (n *ConfigNode) Node() *ConfigNode {
+	return n
+}
+
+func (*ValueItem) dataNode()   {}
+func (*DataSection) dataNode() {}
+
+// DataNodes is the list of the items and sections of a dataSection,
+// a node having a sectionType is decoded as a *DataSection, others as a *ValueItem.
+type DataNodes []DataNode
+
+func (ns *DataNodes) UnmarshalJSON(b []byte) error {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return err
+	}
+	if raws == nil {
+		*ns = nil
+		return nil
+	}
+
+	nodes := make(DataNodes, len(raws))
+	for i, raw := range raws {
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return fmt.Errorf("dataSection node %d: %w", i, err)
+		}
+		var node DataNode
+		if _, isSection := probe["sectionType"]; isSection {
+			node = &DataSection{}
+		} else {
+			node = &ValueItem{}
+		}
+		if err := json.Unmarshal(raw, node); err != nil {
+			return fmt.Errorf("dataSection node %d: %w", i, err)
+		}
+		nodes[i] = node
+	}
+	*ns = nodes
+	return nil
+}
+
+// Find returns the node of id among ns, nil if there isn't one
+func (ns DataNodes) Find(id string) DataNode {
+	for _, n := range ns {
+		if n != nil && n.Node().ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+func (it *ValueItem) UnmarshalJSON(b []byte) (err error) {
+	type plain ValueItem
+	it.Extra, err = unmarshalNode(b, (*plain)(it))
+	return
+}
+
+func (it ValueItem) MarshalJSON() ([]byte, error) {
+	type plain ValueItem
+	return marshalNode(plain(it), it.Extra)
+}
+
+func (sec *DataSection) UnmarshalJSON(b []byte) (err error) {
+	type plain DataSection
+	sec.Extra, err = unmarshalNode(b, (*plain)(sec))
+	return
+}
+
+func (sec DataSection) MarshalJSON() ([]byte, error) {
+	type plain DataSection
+	return marshalNode(plain(sec), sec.Extra)
+}
+
+func (ext *ExternalItem) UnmarshalJSON(b []byte) (err error) {
+	type plain ExternalItem
+	ext.Extra, err = unmarshalNode(b, (*plain)(ext))
+	return
+}
+
+func (ext ExternalItem) MarshalJSON() ([]byte, error) {
+	type plain ExternalItem
+	return marshalNode(plain(ext), ext.Extra)
+}
+
+func (sw *SwitchSection) UnmarshalJSON(b []byte) (err error) {
+	type plain SwitchSection
+	sw.Extra, err = unmarshalNode(b, (*plain)(sw))
+	return
+}
+
+func (sw SwitchSection) MarshalJSON() ([]byte, error) {
+	type plain SwitchSection
+	return marshalNode(plain(sw), sw.Extra)
+}
+
+func (c *CaseItem) UnmarshalJSON(b []byte) (err error) {
+	type plain CaseItem
+	c.Extra, err = unmarshalNode(b, (*plain)(c))
+	return
+}
+
+func (c CaseItem) MarshalJSON() ([]byte, error) {
+	type plain CaseItem
+	return marshalNode(plain(c), c.Extra)
+}
+
+func (cfg *CrawlerConfig) UnmarshalJSON(b []byte) (err error) {
+	type plain CrawlerConfig
+	cfg.Extra, err = unmarshalNode(b, (*plain)(cfg))
+	return
+}
+
+// MarshalJSON leaves out the zero pageLoad, omitempty doesn't apply to a struct
+func (cfg CrawlerConfig) MarshalJSON() ([]byte, error) {
+	type plain CrawlerConfig
+	out := struct {
+		plain
+		PageLoad *PageLoad `json:"pageLoad,omitempty"`
+	}{plain: plain(cfg)}
+	if !cfg.PageLoad.isZero() {
+		out.PageLoad = &cfg.PageLoad
+	}
+	return marshalNode(out, cfg.Extra)
+}
+
+func (pl *PageLoad) UnmarshalJSON(b []byte) (err error) {
+	type plain PageLoad
+	pl.Extra, err = unmarshalNode(b, (*plain)(pl))
+	return
+}
+
+func (pl PageLoad) MarshalJSON() ([]byte, error) {
+	type plain PageLoad
+	return marshalNode(plain(pl), pl.Extra)
+}
+
+func (pl PageLoad) isZero() bool {
+	return pl.Wait == "" && pl.Selector == "" && pl.Sleep == 0 && len(pl.Extra) == 0
+}
+
+func (dl *DownloadConfig) UnmarshalJSON(b []byte) (err error) {
+	type plain DownloadConfig
+	dl.Extra, err = unmarshalNode(b, (*plain)(dl))
+	return
+}
+
+func (dl DownloadConfig) MarshalJSON() ([]byte, error) {
+	type plain DownloadConfig
+	return marshalNode(plain(dl), dl.Extra)
+}
+
+// unmarshalNode decodes b into v, a pointer to a struct, and returns the keys of b unknown by v
+func unmarshalNode(b []byte, v interface{}) (map[string]json.RawMessage, error) {
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, err
+	}
+	if bytes.Equal(bytes.TrimSpace(b), []byte("null")) {
+		return nil, nil
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	known := knownFields(reflect.TypeOf(v).Elem())
+	var extra map[string]json.RawMessage
+	for k, raw := range all {
+		if known[k] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[k] = raw
+	}
+	return extra, nil
+}
+
+// marshalNode encodes v along with the extra keys
+func marshalNode(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return b, err
+	}
+	var all map[string]json.RawMessage
+	if err = json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for k, raw := range extra {
+		if _, has := all[k]; !has {
+			all[k] = raw
+		}
+	}
+	return json.Marshal(all)
+}
+
+var knownFieldsCache sync.Map
+
+// knownFields returns the json keys of the fields of the struct type t, including its embedded structs
+func knownFields(t reflect.Type) map[string]bool {
+	if known, ok := knownFieldsCache.Load(t); ok {
+		return known.(map[string]bool)
+	}
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k := range knownFields(f.Type) {
+				known[k] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		known[name] = true
+	}
+	knownFieldsCache.Store(t, known)
+	return known
+}
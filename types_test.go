@@ -0,0 +1,105 @@
+package rpa
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const typedCfgJson = `{
+	"x-version": 2,
+	"pageLoad": {"wait": "show", "selector": "h1", "note": "slow"},
+	"dataSection": [
+		{"id": "title", "label": "Title", "selector": "h1", "itemType": "text", "valueRender": "return val.trim()", "comment": "kept"},
+		{"id": "list", "selector": ".row", "sectionType": "list", "filterRender": "return !!val", "items": [
+			{"id": "name", "selector": ".name", "itemType": "text"},
+			{"id": "link", "selector": "a", "itemType": "text", "valueProper": "href",
+				"external": {"config": "./detail.json", "id": "detail"}},
+			{"id": "sub", "selector": ".sub", "sectionType": "form", "items": [], "x-note": {"a": [1, 2]}}
+		], "scroll": {"maxRows": 10}, "pagination": {"next": "a.next"}},
+		{"id": "file", "selector": "a.file", "itemType": "download", "downloadId": "zip"}
+	],
+	"switchSection": {
+		"switchRender": "return data.title",
+		"cases": [
+			{"case": "a", "dataSection": [{"id": "price", "selector": ".price", "itemType": "text"}]},
+			{"case": [1, 2], "dataSection": []},
+			{"case": null, "dataSection": [], "note": "fallback"}
+		]
+	},
+	"downloadSection": [{"id": "zip", "selector": "a.zip", "downloadType": "url", "x-folder": "docs"}]
+}`
+
+func TestDataNodes(t *testing.T) {
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(typedCfgJson), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	title, ok := cfg.DataSection[0].(*ValueItem)
+	if !ok || title.ItemType != ItemText || title.ValueRender != "return val.trim()" {
+		t.Fatalf("unexpected item %+v", cfg.DataSection[0])
+	}
+	if string(title.Extra["comment"]) != `"kept"` {
+		t.Errorf("unknown key not kept: %v", title.Extra)
+	}
+
+	list, ok := cfg.DataSection.Find("list").(*DataSection)
+	if !ok || list.SectionType != SectionList || len(list.Items) != 3 {
+		t.Fatalf("unexpected section %+v", cfg.DataSection[1])
+	}
+	link := list.Items.Find("link").(*ValueItem)
+	if link.External == nil || link.External.Config.Path != "./detail.json" || link.External.ID != "detail" {
+		t.Errorf("unexpected external %+v", link.External)
+	}
+	if sub, ok := list.Items[2].(*DataSection); !ok || sub.SectionType != SectionForm || sub.Items == nil {
+		t.Errorf("unexpected nested section %+v", list.Items[2])
+	}
+	if list.Scroll == nil || list.Scroll.MaxRows != 10 || list.Pagination == nil || list.Pagination.Next != "a.next" {
+		t.Errorf("unexpected scroll or pagination %+v %+v", list.Scroll, list.Pagination)
+	}
+
+	if cfg.SwitchSection == nil || len(cfg.SwitchSection.Cases) != 3 {
+		t.Fatalf("unexpected switch section %+v", cfg.SwitchSection)
+	}
+	if _, ok := cfg.SwitchSection.Cases[1].Case.([]interface{}); !ok {
+		t.Errorf("unexpected array case %#v", cfg.SwitchSection.Cases[1].Case)
+	}
+	if cfg.DataSection.Find("missing") != nil {
+		t.Errorf("found a missing node")
+	}
+
+	if string(cfg.Extra["x-version"]) != "2" || string(cfg.PageLoad.Extra["note"]) != `"slow"` ||
+		len(cfg.DownloadSection) != 1 || string(cfg.DownloadSection[0].Extra["x-folder"]) != `"docs"` {
+		t.Errorf("unknown keys of the config not kept: %v %v %v", cfg.Extra, cfg.PageLoad.Extra, cfg.DownloadSection)
+	}
+}
+
+func TestDataNodesRoundTrip(t *testing.T) {
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(typedCfgJson), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected, got map[string]interface{}
+	_ = json.Unmarshal([]byte(typedCfgJson), &expected)
+	_ = json.Unmarshal(b, &got)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("round trip lost data:\nexpected %v\ngot      %v", expected, got)
+	}
+	for _, key := range []string{`"x-version":2`, `"note":"slow"`, `"x-folder":"docs"`} {
+		if !strings.Contains(string(b), key) {
+			t.Errorf("unknown key %s lost: %s", key, b)
+		}
+	}
+
+	cfg.PageLoad = PageLoad{Wait: "show", Selector: "h1"}
+	if b, _ = json.Marshal(&cfg); !strings.Contains(string(b), `"pageLoad":{"wait":"show","selector":"h1"}`) {
+		t.Errorf("pageLoad not written: %s", b)
+	}
+}
@@ -129,7 +129,18 @@ func OpenPage(browser *rod.Browser, url string, sleep int64, selector string, si
 }
 
 func WaitPage(page *rod.Page, sleep int64, selector string, sign WaitSign) (err error) {
-	err = page.WaitStable(time.Second)
+	return WaitPageContext(context.Background(), page, sleep, selector, sign)
+}
+
+// WaitPageContext is like WaitPage but returns ctx.Err() as soon as ctx is done
+func WaitPageContext(ctx context.Context, page *rod.Page, sleep int64, selector string, sign WaitSign) (err error) {
+	return waitPage(ctx, page, sleep, selector, sign, time.Second, 30*time.Second)
+}
+
+// waitPage waits for the page to be stable for stable duration,
+// then waits at most timeout for the selector to show or hide
+func waitPage(ctx context.Context, page *rod.Page, sleep int64, selector string, sign WaitSign, stable, timeout time.Duration) (err error) {
+	err = page.Context(ctx).WaitStable(stable)
 	if err != nil {
 		return err
 	}
@@ -137,14 +148,21 @@ func WaitPage(page *rod.Page, sleep int64, selector string, sign WaitSign) (err
 	if selector != "" {
 		switch sign {
 		case WaitShow:
-			err = WaitElementShow(page, selector, 30)
+			err = waitElementTimeout(ctx, page, selector, timeout, true)
 		case WaitHide:
-			err = WaitElementHide(page, selector, 30)
+			err = waitElementTimeout(ctx, page, selector, timeout, false)
+		}
+		if err != nil {
+			return err
 		}
 	}
 
 	if sleep > 0 {
-		time.Sleep(time.Duration(sleep) * time.Second)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(sleep) * time.Second):
+		}
 	}
 
 	return
@@ -192,7 +210,7 @@ func RaceShow(page *rod.Page, selectors []string, timeoutSeconds int) (int, *rod
 						}()
 
 						if ElementVisible(page, selector) {
-							if elemX, errX := page.Element(selector); errX == nil {
+							if elemX, errX := QueryElem(page, selector); errX == nil {
 								resultChan <- result{index, elemX, nil}
 								return
 							}
@@ -213,24 +231,64 @@ func RaceShow(page *rod.Page, selectors []string, timeoutSeconds int) (int, *rod
 
 // WaitElementHide waits for an element to become invisible on the page
 func WaitElementHide(page *rod.Page, selector string, timeoutSeconds int) error {
-	if !ElementVisible(page, selector) {
-		return nil
-	}
+	return waitElementTimeout(context.Background(), page, selector, time.Duration(timeoutSeconds)*time.Second, false)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(timeoutSeconds)*time.Second)
+// WaitElementShow waits for an element to become visible on the page
+func WaitElementShow(page *rod.Page, selector string, timeoutSeconds int) (err error) {
+	return waitElementTimeout(context.Background(), page, selector, time.Duration(timeoutSeconds)*time.Second, true)
+}
+
+// waitElementTimeout waits for an element to show or hide within timeout,
+// ctx.Err() is returned if ctx is done before that.
+func waitElementTimeout(ctx context.Context, page *rod.Page, selector string, timeout time.Duration, show bool) error {
+	tCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	var err error
+	if show {
+		err = WaitElementShowContext(tCtx, page, selector)
+	} else {
+		err = WaitElementHideContext(tCtx, page, selector)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		if show {
+			return fmt.Errorf("wait element show timed out after %d seconds", int(timeout.Seconds()))
+		}
+		return fmt.Errorf("wait element hide timed out after %d seconds", int(timeout.Seconds()))
+	}
+	return err
+}
+
+// shadowPollTicks is the number of polls of a wait between two reads of the closed shadow roots
+const shadowPollTicks = 10
+
+// WaitElementHideContext waits for an element to become invisible on the page until ctx is done
+func WaitElementHideContext(ctx context.Context, page *rod.Page, selector string) error {
+	roots := newShadowRoots()
+	defer roots.release()
+	visible, err := pollVisible(ctx, page, selector, roots)
+	if err != nil || !visible {
+		return err
+	}
+
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
 	var lastState bool = true
-	for {
+	for tick := 1; ; tick++ {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("wait element hide timed out after %d seconds", timeoutSeconds)
+			return ctx.Err()
 		case <-ticker.C:
-			visible := ElementVisible(page, selector)
+			// the closed shadow roots attached meanwhile are read once a second
+			if tick%shadowPollTicks == 0 {
+				roots.release()
+			}
+			visible, err = pollVisible(ctx, page, selector, roots)
+			if err != nil {
+				return err
+			}
 			if !lastState && !visible {
 				return nil
 			}
@@ -241,28 +299,34 @@ func WaitElementHide(page *rod.Page, selector string, timeoutSeconds int) error
 	}
 }
 
-// WaitElementShow waits for an element to become visible on the page
-func WaitElementShow(page *rod.Page, selector string, timeoutSeconds int) (err error) {
-	if ElementVisible(page, selector) {
-		return
+// WaitElementShowContext waits for an element to become visible on the page until ctx is done
+func WaitElementShowContext(ctx context.Context, page *rod.Page, selector string) error {
+	roots := newShadowRoots()
+	defer roots.release()
+	visible, err := pollVisible(ctx, page, selector, roots)
+	if err != nil || visible {
+		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(timeoutSeconds)*time.Second)
-	defer cancel()
-
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
 	var lastState bool
-	for {
+	for tick := 1; ; tick++ {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("wait element show timed out after %d seconds", timeoutSeconds)
+			return ctx.Err()
 		case <-ticker.C:
-			visible := ElementVisible(page, selector)
+			// the closed shadow roots attached meanwhile are read once a second
+			if tick%shadowPollTicks == 0 {
+				roots.release()
+			}
+			visible, err = pollVisible(ctx, page, selector, roots)
+			if err != nil {
+				return err
+			}
 			if lastState && visible {
-				return
+				return nil
 			}
 			if visible != lastState {
 				lastState = true
@@ -273,37 +337,240 @@ func WaitElementShow(page *rod.Page, selector string, timeoutSeconds int) (err e
 
 // 共享的 JavaScript 代码
 const commonJSCode = `
+const stepPseudoPattern = /:(contains|matches|label)\(|:(next|parent|deep)(?![\w-])/;
+
+const readPseudoArg = (str) => {
+    const quoted = str.match(/^\s*(["'])((?:\\.|(?!\1).)*)\1\s*\)/);
+    if (quoted) {
+        return { arg: quoted[2].replace(/\\(.)/g, '$1'), end: quoted[0].length };
+    }
+    let depth = 0;
+    for (let i = 0; i < str.length; i++) {
+        if (str[i] === '\\') i++;
+        else if (str[i] === '(') depth++;
+        else if (str[i] === ')') {
+            if (depth === 0) return { arg: str.slice(0, i).trim(), end: i + 1 };
+            depth--;
+        }
+    }
+    throw new Error('unclosed pseudo argument: ' + str);
+};
+
+const parseSteps = (selector) => {
+    const steps = [];
+    let rest = selector;
+    let m;
+    while ((m = stepPseudoPattern.exec(rest))) {
+        const step = { css: rest.slice(0, m.index), pseudo: m[1] || m[2] };
+        rest = rest.slice(m.index + m[0].length);
+        if (m[1]) {
+            const { arg, end } = readPseudoArg(rest);
+            step.arg = arg;
+            rest = rest.slice(end);
+        }
+        steps.push(step);
+    }
+    steps.push({ css: rest });
+    return steps;
+};
+
+const compoundLength = (css) => {
+    let depth = 0;
+    for (let i = 0; i < css.length; i++) {
+        const c = css[i];
+        if (c === '\\') i++;
+        else if (c === '[' || c === '(') depth++;
+        else if (c === ']' || c === ')') depth--;
+        else if (depth === 0 && /[\s>+~]/.test(c)) return i;
+    }
+    return css.length;
+};
+
+// the closed shadow roots by their hosts, set from the argument handed by Go, see shadowRoots
+let closedRoots = null;
+
+const shadowRootOf = (elem) => {
+    if (elem.shadowRoot) return elem.shadowRoot;
+    return (closedRoots && closedRoots.get(elem)) || null;
+};
+
+const deepRoots = (node) => {
+    const roots = [node];
+    const hosts = Array.from(node.querySelectorAll('*'));
+    if (node.nodeType === Node.ELEMENT_NODE) hosts.unshift(node);
+    hosts.forEach((el) => {
+        const root = shadowRootOf(el);
+        if (root) roots.push(...deepRoots(root));
+    });
+    return roots;
+};
+
+const uniqueElements = (nodes) => Array.from(new Set(nodes.filter((n) => !!n)));
+
+const normalizedText = (elem) => (elem.textContent || '').replace(/\s+/g, ' ').trim();
+
+const labelTarget = (label) => {
+    let next = label.nextElementSibling;
+    if (label.tagName === 'LABEL') return label.control || next;
+    if (label.tagName === 'DT') {
+        while (next && next.tagName !== 'DD') next = next.nextElementSibling;
+        return next;
+    }
+    if (next && next.tagName === 'TD') return next;
+
+    const table = label.closest('table');
+    if (!table) return null;
+    const rows = Array.from(table.rows);
+    for (let i = rows.indexOf(label.parentElement) + 1; i < rows.length; i++) {
+        const below = rows[i].cells[label.cellIndex];
+        if (below && below.tagName === 'TD') return below;
+    }
+    return null;
+};
+
+const selectSteps = (doc, selector) => {
+    let nodes = [doc];
+    let scoped = false;
+    let deep = false;
+    parseSteps(selector).forEach((step) => {
+        let css = step.css;
+        const filter = step.pseudo === 'contains' || step.pseudo === 'matches';
+        const innermost = filter && /(^|[\s>+~])$/.test(css) && (css !== '' || !scoped || deep);
+        if (innermost) css += '*';
+
+        if (css.trim() && deep) {
+            const found = [];
+            nodes.forEach((n) => deepRoots(n).forEach((root) => {
+                found.push(...root.querySelectorAll(root === n && scoped ? ':scope ' + css.trim() : css.trim()));
+            }));
+            nodes = uniqueElements(found);
+            scoped = true;
+            deep = false;
+        } else if (css.trim()) {
+            let rest = css;
+            if (scoped) {
+                const compound = css.slice(0, compoundLength(css));
+                rest = css.slice(compound.length).trim();
+                if (compound) nodes = nodes.filter((n) => n.matches(compound));
+                rest = rest && ':scope ' + rest;
+            }
+            if (rest) {
+                const found = [];
+                nodes.forEach((n) => found.push(...n.querySelectorAll(rest)));
+                nodes = uniqueElements(found);
+            }
+            scoped = true;
+        }
+
+        switch (step.pseudo) {
+            case 'contains':
+                nodes = nodes.filter((n) => normalizedText(n).includes(step.arg));
+                break;
+            case 'matches': {
+                const m = step.arg.match(/^\/(.*)\/([a-z]*)$/);
+                const re = m ? new RegExp(m[1], m[2]) : new RegExp(step.arg);
+                nodes = nodes.filter((n) => re.test(normalizedText(n)));
+                break;
+            }
+            case 'label': {
+                const labels = [];
+                nodes.forEach((n) => labels.push(...n.querySelectorAll('label, dt, th')));
+                nodes = uniqueElements(labels.filter((l) => normalizedText(l).replace(/\s*[:：]$/, '') === step.arg).map(labelTarget));
+                scoped = true;
+                break;
+            }
+            case 'next':
+                nodes = uniqueElements(nodes.map((n) => n.nextElementSibling));
+                break;
+            case 'parent':
+                nodes = uniqueElements(nodes.map((n) => n.parentElement));
+                break;
+            case 'deep':
+                deep = true;
+                break;
+        }
+        if (innermost) nodes = nodes.filter((n) => !nodes.some((o) => o !== n && n.contains(o)));
+    });
+    return scoped ? nodes : [];
+};
+
+const selectAll = (doc, selector) => {
+    if (!selector.startsWith('xpath:')) {
+        return stepPseudoPattern.test(selector) ? selectSteps(doc, selector) : Array.from(doc.querySelectorAll(selector));
+    }
+    const ownerDoc = doc.ownerDocument || doc;
+    const snapshot = ownerDoc.evaluate(selector.slice(6), doc, null, XPathResult.ORDERED_NODE_SNAPSHOT_TYPE, null);
+    const nodes = [];
+    for (let i = 0; i < snapshot.snapshotLength; i++) {
+        const node = snapshot.snapshotItem(i);
+        if (node.nodeType === Node.ELEMENT_NODE) nodes.push(node);
+    }
+    return nodes;
+};
+
+const selectOne = (doc, selector) => selector.startsWith('xpath:') || stepPseudoPattern.test(selector) ? selectAll(doc, selector)[0] || null : doc.querySelector(selector);
+
 const replacePseudo = (selector, parentElement = document) => {
     let doc = parentElement;
-    const pseudoMatch = selector.match(/^:(frame|shadow)\((.+?)\)/);
+    const pseudoMatch = selector.match(/^:(frame|shadow)\(/);
     
     if (!pseudoMatch) {
         return { doc, selector, ctxChanged: false };
     }
     
-    const [, pseudoType, pseudoSelector] = pseudoMatch;
-    const pseudoElem = parentElement.querySelector(pseudoSelector);
+    // the argument is read up to its balanced ')', an xpath may have brackets of its own
+    const pseudoType = pseudoMatch[1];
+    const { arg: pseudoSelector, end } = readPseudoArg(selector.slice(pseudoMatch[0].length));
+    const pseudoElem = selectOne(parentElement, pseudoSelector);
     
     if (!pseudoElem) {
         return { doc, selector, ctxChanged: false };
     }
     
-    doc = pseudoType === 'frame' ? pseudoElem.contentWindow.document : pseudoElem.shadowRoot;
-    selector = selector.slice(pseudoMatch[0].length).trim();
+    // the document of a cross-origin frame is null, it's reached from Go, see crossFrame
+    doc = pseudoType === 'frame' ? pseudoElem.contentDocument || document.createElement('div') : shadowRootOf(pseudoElem);
+    selector = selector.slice(pseudoMatch[0].length + end).trim();
     
     return /^:(frame|shadow)\(/.test(selector) ? replacePseudo(selector, doc) : { doc, selector, ctxChanged: true };
 };
 
 const queryElem = (selector, parentElement = document) => {
     const { doc, selector: finalSelector } = replacePseudo(selector, parentElement);
-    return doc.querySelector(finalSelector);
+    return selectOne(doc, finalSelector);
+};
+
+const queryElems = (selector, parentElement = document) => {
+    const { doc, selector: finalSelector } = replacePseudo(selector, parentElement);
+    return selectAll(doc, finalSelector);
 };
 `
 
-// ElementVisible checks if an element is visible on the page
+// ElementVisible checks if an element is visible on the page, it's false if the page can't be checked,
+// the cross-origin frames of the :frame() pseudos are reached through their own targets
 func ElementVisible(page *rod.Page, selector string) bool {
-	jsCode := fmt.Sprintf(`(selector) => {
+	roots := newShadowRoots()
+	defer roots.release()
+	visible, _ := elementVisible(page, selector, roots)
+	return visible
+}
+
+// elementVisible is ElementVisible returning the error of the check, the closed shadow roots are read from roots
+func elementVisible(page *rod.Page, selector string, roots *shadowRoots) (bool, error) {
+	if frame, rest, err := crossFrame(page, nil, selector, roots); err == nil && frame != nil {
+		return elementVisible(frame, rest, roots)
+	}
+
+	var rootsArg interface{}
+	if usesShadow(selector) {
+		var err error
+		if rootsArg, err = roots.arg(page); err != nil {
+			return false, err
+		}
+	}
+
+	jsCode := fmt.Sprintf(`(selector, roots) => {
 		%s
+        closedRoots = roots;
         try {
             const elem = queryElem(selector);
             if (!elem) return false;
@@ -315,13 +582,51 @@ func ElementVisible(page *rod.Page, selector string) bool {
         }
     }`, commonJSCode)
 
-	return page.MustEval(jsCode, selector).Bool()
+	res, err := page.Eval(jsCode, selector, rootsArg)
+	if err != nil {
+		return false, err
+	}
+	return res.Value.Bool(), nil
 }
 
-// QueryElem returns the element matching the selector
+// elementVisibleContext is like ElementVisible but returns the error of the check,
+// ctx.Err() is returned if ctx is done.
+func elementVisibleContext(ctx context.Context, page *rod.Page, selector string) (visible bool, err error) {
+	roots := newShadowRoots()
+	defer roots.release()
+	return pollVisible(ctx, page, selector, roots)
+}
+
+// pollVisible is elementVisibleContext for the polls of a wait, which keep the closed shadow roots in roots
+// between the polls instead of walking the whole DOM every time
+func pollVisible(ctx context.Context, page *rod.Page, selector string, roots *shadowRoots) (visible bool, err error) {
+	visible, err = elementVisible(page.Context(ctx), selector, roots)
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return visible, err
+}
+
+// QueryElem returns the element matching the selector,
+// the cross-origin frames of the :frame() pseudos are reached through their own targets
 func QueryElem(page *rod.Page, selector string) (*rod.Element, error) {
-	jsCode := fmt.Sprintf(`(selector) => {
+	roots := newShadowRoots()
+	defer roots.release()
+	if frame, rest, err := crossFrame(page, nil, selector, roots); err == nil && frame != nil {
+		return QueryElem(frame, rest)
+	}
+
+	var rootsArg interface{}
+	if usesShadow(selector) {
+		var err error
+		if rootsArg, err = roots.arg(page); err != nil {
+			return nil, err
+		}
+	}
+
+	jsCode := fmt.Sprintf(`(selector, roots) => {
 		%s
+        closedRoots = roots;
         try {
             return queryElem(selector) || null;
         } catch {
@@ -333,11 +638,33 @@ func QueryElem(page *rod.Page, selector string) (*rod.Element, error) {
 		JS: jsCode,
 		JSArgs: []interface{}{
 			selector,
+			rootsArg,
 		},
 	}
 	return page.ElementByJS(opts)
 }
 
+// queryElems returns the elements matching the selector
+func queryElems(page *rod.Page, selector string) (rod.Elements, error) {
+	var rootsArg interface{}
+	if usesShadow(selector) {
+		roots := newShadowRoots()
+		defer roots.release()
+		var err error
+		if rootsArg, err = roots.arg(page); err != nil {
+			return nil, err
+		}
+	}
+
+	jsCode := fmt.Sprintf(`(selector, roots) => {
+		%s
+        closedRoots = roots;
+        return queryElems(selector);
+    }`, commonJSCode)
+
+	return page.ElementsByJS(rod.Eval(jsCode, selector, rootsArg))
+}
+
 // RenameFileUnique generates a unique filename by appending a number if the file already exists
 func RenameFileUnique(dir, fileName, ext string) string {
 	for try := 0; try < 1000; try++ {
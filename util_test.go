@@ -1,79 +1,95 @@
-package rpa
+package rpa_test
 
 import (
-	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/proto"
 	"testing"
-)
-
-func getPage() (*rod.Page, error) {
-	b, e := ConnectChromiumBrowser(true, false)
-	if e != nil {
-		return nil, e
-	}
 
-	p, e := b.Page(proto.TargetCreateTarget{
-		URL:       `https://goplay.tools/`,
-		NewWindow: false,
-	})
-	if e != nil {
-		return nil, e
-	}
+	rpa "github.com/rpdg/rod-helper"
+	"github.com/rpdg/rod-helper/rpatest"
+)
 
-	return p, nil
+// newHarness serves testdata/site, its wait.html hides .loader and shows .logo after a second
+func newHarness(t *testing.T) *rpatest.Harness {
+	return rpatest.New(t, "testdata/site")
 }
 
 func Test_WaitElementHide(t *testing.T) {
-	p, e := getPage()
-	if e != nil {
-		t.Fatal(e)
-	}
+	p := newHarness(t).Page("wait.html")
 
-	e = WaitElementShow(p, ".app-preloader__content", 20)
+	e := rpa.WaitElementShow(p, ".loader", 20)
 	if e != nil {
 		t.Fatal(e)
 	}
-	e = WaitElementHide(p, ".app-preloader__content", 20)
+	e = rpa.WaitElementHide(p, ".loader", 20)
 	if e != nil {
 		t.Fatal(e)
 	}
 	t.Log("loading hidden")
 
-	e = WaitElementShow(p, ".view-lines", 20)
+	e = rpa.WaitElementShow(p, ".logo", 20)
 	if e != nil {
 		t.Fatal(e)
 	}
-	t.Log("editor shown")
+	t.Log("logo shown")
 }
 
 func Test_QueryElem(t *testing.T) {
-	p, err := getPage()
+	p := newHarness(t).Page("wait.html")
+
+	err := rpa.WaitElementShow(p, ".logo", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ele, err := rpa.QueryElem(p, ".logo")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if text, _ := ele.Text(); text != "Logo" {
+		t.Errorf("unexpected element %q", text)
+	}
 
-	err = WaitElementShow(p, ".header__logo", 20)
+	ele, err = rpa.QueryElem(p, "xpath://div[@class='content']/span")
 	if err != nil {
 		t.Fatal(err)
 	}
+	if text, _ := ele.Text(); text != "Logo" {
+		t.Errorf("unexpected xpath element %q", text)
+	}
 
-	ele, err := QueryElem(p, ".header__logo")
+	ele, err = rpa.QueryElem(p, ".content :contains(Logo)")
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Log(ele)
+	if tag, _ := ele.Property("tagName"); tag.Str() != "SPAN" {
+		t.Errorf("expected the innermost element, got %s", tag.Str())
+	}
 }
 
 func Test_RaceShow(t *testing.T) {
-	p, err := getPage()
+	p := newHarness(t).Page("wait.html")
+
+	idx, _, err := rpa.RaceShow(p, []string{".missing", ".logo"}, 20)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if idx != 1 {
+		t.Errorf("expected .logo to show, got %d", idx)
+	}
+}
+
+func Test_ElementVisibleShadow(t *testing.T) {
+	p := newHarness(t).Page("shadow.html")
 
-	idx, ele, err := RaceShow(p, []string{".header__logo", ".app-preloader__content", ".view-lines"}, 20)
+	err := rpa.WaitElementShow(p, ":shadow(#card) .price", 20)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Log(idx, ele)
+	ele, err := rpa.QueryElem(p, ":deep .msg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := ele.Text(); text != "Deep inside" {
+		t.Errorf("unexpected element %q", text)
+	}
 }
@@ -0,0 +1,513 @@
+package rpa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConfigError is a problem of a config found by ValidateConfig, or a warning of ConfigWarnings
+type ConfigError struct {
+	// Pointer is the JSON pointer of the invalid value, such as "/dataSection/0/items/2/itemType"
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Message)
+}
+
+// ConfigErrors is all the problems of a config
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ce := range e {
+		msgs[i] = ce.Error()
+	}
+	return "invalid config:\n" + strings.Join(msgs, "\n")
+}
+
+var (
+	waitSigns     = []string{"", string(WaitShow), string(WaitHide), string(WaitDelay)}
+	downloadTypes = []string{string(DownloadUrl), string(DownloadElement), string(PrintToPDF)}
+	nextTypes     = []string{"", string(NextClick), string(NextHref)}
+	actionTypes   = []string{"click", "input", "select", "press", "hover", "scroll", "waitShow", "waitHide", "sleep", "eval"}
+	itemTypes     = []string{"text", "textBox", "radioBox", "checkBox", "dropBox", "download"}
+	sectionTypes  = []string{"form", "list"}
+)
+
+// ValidateConfig checks cfg before running it, it returns ConfigErrors listing every problem found.
+//
+// It checks the required fields, the enum values, the duplicate ids, the downloadId references
+// and the insertTo paths. The render strings aren't parsed, their JavaScript errors show up in Result.Errors
+// when the crawl runs, see ConfigWarnings for a light check of them.
+func ValidateConfig(cfg *CrawlerConfig) error {
+	v := &cfgValidator{}
+	v.config(cfg, "")
+	if len(v.errs) > 0 {
+		return v.errs
+	}
+	return nil
+}
+
+// ConfigWarnings lists the likely mistakes of cfg that don't stop it from loading,
+// such as a render string with unbalanced brackets or an unterminated string.
+// The check of the renders is a heuristic, a warning may be reported on valid JavaScript.
+func ConfigWarnings(cfg *CrawlerConfig) []ConfigError {
+	v := &cfgValidator{}
+	v.config(cfg, "")
+	return v.warns
+}
+
+type cfgValidator struct {
+	errs  ConfigErrors
+	warns []ConfigError
+}
+
+func (v *cfgValidator) fail(pointer string, format string, args ...interface{}) {
+	v.errs = append(v.errs, ConfigError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *cfgValidator) warn(pointer string, format string, args ...interface{}) {
+	v.warns = append(v.warns, ConfigError{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *cfgValidator) config(cfg *CrawlerConfig, ptr string) {
+	if cfg == nil {
+		v.fail(ptr, "config is null")
+		return
+	}
+
+	v.pageLoad(cfg.PageLoad, ptr+"/pageLoad")
+	v.actions(cfg.Actions, ptr+"/actions")
+	if cfg.Login != nil {
+		v.login(cfg.Login, ptr+"/login")
+	}
+
+	downloadIds := make(map[string]bool)
+	seen := make(map[string]int)
+	for i, dl := range cfg.DownloadSection {
+		p := fmt.Sprintf("%s/downloadSection/%d", ptr, i)
+		if dl.ID == "" {
+			v.fail(p+"/id", "missing id")
+		} else if j, dup := seen[dl.ID]; dup {
+			v.fail(p+"/id", "duplicate id %q, already used by /downloadSection/%d", dl.ID, j)
+		} else {
+			seen[dl.ID] = i
+			downloadIds[dl.ID] = true
+		}
+		if dl.Selector == "" {
+			v.fail(p+"/selector", "missing selector")
+		}
+		v.enum(p+"/downloadType", string(dl.DownloadType), downloadTypes)
+		if dl.NameRender != "auto" {
+			v.render(p+"/nameRender", dl.NameRender)
+		}
+		v.render(p+"/linkRender", dl.LinkRender)
+	}
+
+	v.nodes(cfg.DataSection, ptr+"/dataSection", downloadIds)
+
+	if sw := cfg.SwitchSection; sw != nil {
+		p := ptr + "/switchSection"
+		if sw.SwitchRender == "" {
+			v.fail(p+"/switchRender", "missing switchRender")
+		} else {
+			v.render(p+"/switchRender", sw.SwitchRender)
+		}
+		for i, c := range sw.Cases {
+			v.nodes(c.DataSection, fmt.Sprintf("%s/cases/%d/dataSection", p, i), downloadIds)
+			v.actions(c.Actions, fmt.Sprintf("%s/cases/%d/actions", p, i))
+		}
+	}
+
+	if cfg.Pagination != nil {
+		v.pagination(cfg.Pagination, ptr+"/pagination")
+	}
+
+	for i, dl := range cfg.DownloadSection {
+		if dl.InsertTo != "" {
+			v.insertTo(cfg, dl.InsertTo, fmt.Sprintf("%s/downloadSection/%d/insertTo", ptr, i))
+		}
+	}
+}
+
+func (v *cfgValidator) pageLoad(pl PageLoad, ptr string) {
+	v.enum(ptr+"/wait", string(pl.Wait), waitSigns)
+	if (pl.Wait == WaitShow || pl.Wait == WaitHide) && pl.Selector == "" {
+		v.fail(ptr+"/selector", "wait %q requires a selector", pl.Wait)
+	}
+}
+
+func (v *cfgValidator) login(lp *LoginProfile, ptr string) {
+	if lp.LoggedOut == "" {
+		v.fail(ptr+"/loggedOut", "missing loggedOut selector")
+	}
+	if lp.Success == "" {
+		v.fail(ptr+"/success", "missing success selector")
+	}
+	if len(lp.Fields) == 0 {
+		v.fail(ptr+"/fields", "missing fields")
+	}
+	for i, f := range lp.Fields {
+		p := fmt.Sprintf("%s/fields/%d", ptr, i)
+		if f.Selector == "" {
+			v.fail(p+"/selector", "missing selector")
+		}
+		if f.Env != "" && f.Secret != "" {
+			v.fail(p, "env and secret are exclusive")
+		}
+	}
+}
+
+func (v *cfgValidator) actions(actions []Action, ptr string) {
+	for i, act := range actions {
+		p := fmt.Sprintf("%s/%d", ptr, i)
+		v.enum(p+"/type", string(act.Type), actionTypes)
+		switch act.Type {
+		case ActionClick, ActionInput, ActionSelect, ActionHover, ActionWaitShow, ActionWaitHide:
+			if act.Selector == "" {
+				v.fail(p+"/selector", "action %q requires a selector", act.Type)
+			}
+		}
+		switch act.Type {
+		case ActionPress:
+			if _, err := parseKeys(act.Value); err != nil {
+				v.fail(p+"/value", "%v", err)
+			}
+		case ActionScroll:
+			if act.Selector == "" && act.Value != "" {
+				if _, err := strconv.ParseFloat(act.Value, 64); err != nil {
+					v.fail(p+"/value", "scroll value %q is not a number of pixels", act.Value)
+				}
+			}
+		case ActionSleep:
+			if act.Sleep <= 0 {
+				v.fail(p+"/sleep", "sleep requires the milliseconds to sleep")
+			}
+			if act.Timeout > 0 {
+				v.fail(p+"/timeout", "timeout is the seconds to wait for an element, sleep takes its milliseconds in sleep")
+			}
+		case ActionEval:
+			if act.Script == "" {
+				v.fail(p+"/script", "missing script")
+			} else {
+				v.render(p+"/script", act.Script)
+			}
+		}
+	}
+}
+
+func (v *cfgValidator) pagination(pg *Pagination, ptr string) {
+	if pg.Next == "" {
+		v.fail(ptr+"/next", "missing next selector")
+	}
+	v.enum(ptr+"/nextType", string(pg.NextType), nextTypes)
+	v.render(ptr+"/stopRender", pg.StopRender)
+	if pg.PageLoad != nil {
+		v.pageLoad(*pg.PageLoad, ptr+"/pageLoad")
+	}
+}
+
+// nodes checks the sibling items and sections of a dataSection or the items of a section
+func (v *cfgValidator) nodes(nodes DataNodes, ptr string, downloadIds map[string]bool) {
+	seen := make(map[string]int)
+	for i, n := range nodes {
+		p := fmt.Sprintf("%s/%d", ptr, i)
+		if n == nil {
+			v.fail(p, "node is null")
+			continue
+		}
+
+		node := n.Node()
+		if node.ID == "" {
+			v.fail(p+"/id", "missing id")
+		} else if j, dup := seen[node.ID]; dup {
+			v.fail(p+"/id", "duplicate id %q, already used by %s/%d", node.ID, ptr, j)
+		} else {
+			seen[node.ID] = i
+		}
+		if node.Selector == "" {
+			v.fail(p+"/selector", "missing selector")
+		}
+		v.render(p+"/domRender", node.DomRender)
+
+		switch val := n.(type) {
+		case *ValueItem:
+			v.item(val, p, downloadIds)
+		case *DataSection:
+			v.section(val, p, downloadIds)
+		}
+	}
+}
+
+func (v *cfgValidator) item(it *ValueItem, ptr string, downloadIds map[string]bool) {
+	if it.ItemType == "" {
+		v.fail(ptr, "missing itemType or sectionType")
+	} else {
+		v.enum(ptr+"/itemType", string(it.ItemType), itemTypes)
+	}
+	v.render(ptr+"/valueRender", it.ValueRender)
+
+	if it.ItemType == ItemDownload {
+		if it.DownloadID == "" {
+			v.fail(ptr+"/downloadId", "missing downloadId of download item")
+		} else if !downloadIds[it.DownloadID] {
+			v.fail(ptr+"/downloadId", "downloadId %q not found in downloadSection", it.DownloadID)
+		}
+	}
+
+	if ext := it.External; ext != nil {
+		p := ptr + "/external/config"
+		switch {
+		case ext.Config.Inline != nil:
+			v.config(ext.Config.Inline, p)
+		case ext.Config.Path == "":
+			v.fail(p, "missing config")
+		}
+	}
+}
+
+func (v *cfgValidator) section(sec *DataSection, ptr string, downloadIds map[string]bool) {
+	v.enum(ptr+"/sectionType", string(sec.SectionType), sectionTypes)
+	v.render(ptr+"/filterRender", sec.FilterRender)
+	v.render(ptr+"/dataRender", sec.DataRender)
+
+	if sec.Items == nil {
+		v.fail(ptr+"/items", "missing items")
+	}
+	v.nodes(sec.Items, ptr+"/items", downloadIds)
+
+	if sec.Pagination != nil {
+		v.pagination(sec.Pagination, ptr+"/pagination")
+	}
+}
+
+// insertTo checks the dotted path of a download insertTo leads to a form section item of the dataSection
+func (v *cfgValidator) insertTo(cfg *CrawlerConfig, insertTo, ptr string) {
+	level := append(DataNodes{}, cfg.DataSection...)
+	if cfg.SwitchSection != nil {
+		for _, c := range cfg.SwitchSection.Cases {
+			level = append(level, c.DataSection...)
+		}
+	}
+
+	keys := strings.Split(insertTo, ".")
+	for i, key := range keys {
+		found := level.Find(key)
+		if found == nil {
+			v.fail(ptr, "%q not found in dataSection", strings.Join(keys[:i+1], "."))
+			return
+		}
+		if i == len(keys)-1 {
+			return
+		}
+		sec, ok := found.(*DataSection)
+		if !ok || sec.SectionType != SectionForm {
+			v.fail(ptr, "%q is not a form section", strings.Join(keys[:i+1], "."))
+			return
+		}
+		level = sec.Items
+	}
+}
+
+func (v *cfgValidator) enum(ptr, val string, allowed []string) {
+	for _, a := range allowed {
+		if val == a {
+			return
+		}
+	}
+	var quoted []string
+	for _, a := range allowed {
+		if a != "" {
+			quoted = append(quoted, strconv.Quote(a))
+		}
+	}
+	if val == "" {
+		v.fail(ptr, "missing value, expected one of %s", strings.Join(quoted, ", "))
+		return
+	}
+	v.fail(ptr, "invalid value %q, expected one of %s", val, strings.Join(quoted, ", "))
+}
+
+func (v *cfgValidator) render(ptr, code string) {
+	if code == "" {
+		return
+	}
+	if err := checkJsBalance(code); err != nil {
+		v.warn(ptr, "unbalanced brackets or quotes in render: %s", err)
+	}
+}
+
+// keywords after which a slash starts a regular expression instead of a division
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// checkJsBalance is a light check of a JavaScript function body, not a parser: it finds the unbalanced brackets
+// and the unterminated strings, template literals and comments. The other syntax errors show when the render runs.
+// A slash starts a regular expression or a division depending on the token before it, a slash that doesn't start
+// a regular expression ending on its line is a division. A regular expression right after ')' or '}',
+// such as in "if (ok) /[(]/.test(s)", is taken as a division and may be reported.
+func checkJsBalance(code string) error {
+	src := []rune(code)
+	n := len(src)
+	// open brackets, '$' stands for the ${ of a template literal
+	var stack []rune
+	var offsets []int
+	// the last significant token, to tell a regular expression from a division
+	lastTok := ""
+
+	isIdent := func(r rune) bool {
+		return r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 0x7f
+	}
+
+	// template scans a template literal from i, which is after the backtick or the } of a ${},
+	// it returns the index after the closing backtick, or after the ${ with ok false.
+	template := func(i int) (next int, closed bool, err error) {
+		for i < n {
+			switch src[i] {
+			case '\\':
+				i += 2
+				continue
+			case '`':
+				return i + 1, true, nil
+			case '$':
+				if i+1 < n && src[i+1] == '{' {
+					return i + 2, false, nil
+				}
+			}
+			i++
+		}
+		return n, false, fmt.Errorf("unterminated template literal")
+	}
+
+	for i := 0; i < n; {
+		r := src[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+		case r == '/' && i+1 < n && src[i+1] == '/':
+			for i < n && src[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < n && src[i+1] == '*':
+			start := i
+			i += 2
+			for i+1 < n && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			if i+1 >= n {
+				return fmt.Errorf("unterminated comment at offset %d", start)
+			}
+			i += 2
+		case r == '\'' || r == '"':
+			start := i
+			i++
+			for i < n && src[i] != r {
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '\n' {
+					break
+				}
+				i++
+			}
+			if i >= n || src[i] != r {
+				return fmt.Errorf("unterminated string at offset %d", start)
+			}
+			i++
+			lastTok = `"`
+		case r == '`':
+			next, closed, err := template(i + 1)
+			if err != nil {
+				return fmt.Errorf("%s at offset %d", err, i)
+			}
+			if !closed {
+				stack, offsets = append(stack, '$'), append(offsets, i)
+			}
+			i = next
+			lastTok = `"`
+			if !closed {
+				lastTok = "{"
+			}
+		case r == '/' && (lastTok == "" || strings.ContainsAny(lastTok, "([{,;:=!&|?+-*%<>~^") || regexKeywords[lastTok]):
+			start := i
+			i++
+			inClass := false
+			for i < n && src[i] != '\n' && (inClass || src[i] != '/') {
+				switch src[i] {
+				case '\\':
+					i++
+				case '[':
+					inClass = true
+				case ']':
+					inClass = false
+				}
+				i++
+			}
+			if i >= n || src[i] != '/' {
+				// not a regular expression, such as the division of obj.in / 2
+				i = start + 1
+				lastTok = "/"
+				continue
+			}
+			i++
+			for i < n && isIdent(src[i]) {
+				i++
+			}
+			lastTok = `"`
+		case r == '(' || r == '[' || r == '{':
+			stack, offsets = append(stack, r), append(offsets, i)
+			i++
+			lastTok = string(r)
+		case r == ')' || r == ']' || r == '}':
+			open := map[rune]rune{')': '(', ']': '[', '}': '{'}[r]
+			if len(stack) == 0 {
+				return fmt.Errorf("unexpected %q at offset %d", r, i)
+			}
+			top := stack[len(stack)-1]
+			if top == '$' && r == '}' {
+				stack, offsets = stack[:len(stack)-1], offsets[:len(offsets)-1]
+				next, closed, err := template(i + 1)
+				if err != nil {
+					return fmt.Errorf("%s at offset %d", err, i)
+				}
+				if !closed {
+					stack, offsets = append(stack, '$'), append(offsets, i)
+				}
+				i = next
+				lastTok = `"`
+				if !closed {
+					lastTok = "{"
+				}
+				continue
+			}
+			if top != open {
+				return fmt.Errorf("unexpected %q at offset %d", r, i)
+			}
+			stack, offsets = stack[:len(stack)-1], offsets[:len(offsets)-1]
+			i++
+			lastTok = string(r)
+		case isIdent(r):
+			start := i
+			for i < n && isIdent(src[i]) {
+				i++
+			}
+			lastTok = string(src[start:i])
+		default:
+			i++
+			lastTok = string(r)
+		}
+	}
+
+	if len(stack) > 0 {
+		open := stack[len(stack)-1]
+		if open == '$' {
+			return fmt.Errorf("unterminated template literal at offset %d", offsets[len(offsets)-1])
+		}
+		return fmt.Errorf("unclosed %q at offset %d", open, offsets[len(offsets)-1])
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package rpa
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cfgJson := `{
+		"pageLoad": {"wait": "shwo", "selector": ".main"},
+		"dataSection": [
+			{"id": "list", "selector": ".row", "sectionType": "lsit", "items": [
+				{"id": "title", "selector": "h3", "itemType": "text", "valueRender": "return val.trim("},
+				{"id": "title", "selector": "h4", "itemType": "text"},
+				{"id": "file", "selector": "a", "itemType": "download", "downloadId": "missing"}
+			]},
+			{"selector": ".form", "sectionType": "form", "items": []},
+			{"id": "detail", "selector": "a.detail", "itemType": "text",
+				"external": {"config": {"dataSection": [{"id": "price", "selector": ".price", "itemType": "number"}]}}}
+		],
+		"downloadSection": [
+			{"id": "zip", "selector": "a.zip", "downloadType": "link", "insertTo": "list.file"}
+		]
+	}`
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(cfgJson), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateConfig(&cfg)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	got := make(map[string]bool)
+	for _, e := range errs {
+		got[e.Pointer] = true
+	}
+	for _, p := range []string{
+		"/pageLoad/wait",
+		"/downloadSection/0/downloadType",
+		"/downloadSection/0/insertTo",
+		"/dataSection/0/sectionType",
+		"/dataSection/0/items/1/id",
+		"/dataSection/0/items/2/downloadId",
+		"/dataSection/1/id",
+		"/dataSection/2/external/config/dataSection/0/itemType",
+	} {
+		if !got[p] {
+			t.Errorf("expected an error at %s, got %v", p, errs)
+		}
+	}
+	if len(errs) != 8 {
+		t.Errorf("expected 8 errors, got %d: %v", len(errs), errs)
+	}
+	warns := ConfigWarnings(&cfg)
+	if len(warns) != 1 || warns[0].Pointer != "/dataSection/0/items/0/valueRender" {
+		t.Errorf("expected a warning at /dataSection/0/items/0/valueRender, got %v", warns)
+	}
+
+	var sample CrawlerConfig
+	if err := json.Unmarshal([]byte(`{
+		"dataSection": [{"id": "list", "selector": ".row", "sectionType": "list", "items": [
+			{"id": "title", "selector": "h3", "itemType": "text", "valueRender": "return val.replace(/[()]/g, '')"}
+		]}],
+		"downloadSection": [{"id": "zip", "selector": "a.zip", "downloadType": "url"}]
+	}`), &sample); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfig(&sample); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+	if warns := ConfigWarnings(&sample); len(warns) != 0 {
+		t.Errorf("expected no warnings, got %v", warns)
+	}
+
+	// the heuristic misreads this regular expression as a division, its warning doesn't block the config
+	var misread CrawlerConfig
+	if err := json.Unmarshal([]byte(`{
+		"dataSection": [{"id": "title", "selector": "h3", "itemType": "text", "valueRender": "if (val) /[(]/.test(val); return val"}]
+	}`), &misread); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateConfig(&misread); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidateConfigActions(t *testing.T) {
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(`{
+		"actions": [
+			{"type": "click", "selector": "#tab"},
+			{"type": "clik", "selector": "#tab"},
+			{"type": "input", "value": "apple"},
+			{"type": "press", "value": "Control+Nope"},
+			{"type": "scroll", "value": "down"},
+			{"type": "sleep"},
+			{"type": "eval", "script": "this.click("},
+			{"type": "sleep", "sleep": 500, "timeout": 500}
+		],
+		"dataSection": [{"id": "title", "selector": "h1", "itemType": "text"}],
+		"switchSection": {"switchRender": "return 1", "cases": [
+			{"case": 1, "dataSection": [], "actions": [{"type": "waitShow"}, {"type": "press", "value": "Shift++"}]}
+		]}
+	}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateConfig(&cfg)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	want := []string{
+		"/actions/1/type",
+		"/actions/2/selector",
+		"/actions/3/value",
+		"/actions/4/value",
+		"/actions/5/sleep",
+		"/actions/7/timeout",
+		"/switchSection/cases/0/actions/0/selector",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, p := range want {
+		if errs[i].Pointer != p {
+			t.Errorf("error %d: expected %s, got %v", i, p, errs[i])
+		}
+	}
+}
+
+func TestValidateConfigLogin(t *testing.T) {
+	var cfg CrawlerConfig
+	if err := json.Unmarshal([]byte(`{
+		"login": {"loggedOut": "#login", "fields": [
+			{"selector": "#user", "env": "APP_USER"},
+			{"env": "APP_PASSWORD", "secret": "password"}
+		]},
+		"dataSection": []
+	}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ValidateConfig(&cfg)
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+	want := []string{"/login/success", "/login/fields/1/selector", "/login/fields/1"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, p := range want {
+		if errs[i].Pointer != p {
+			t.Errorf("error %d: expected %s, got %v", i, p, errs[i])
+		}
+	}
+}
+
+func Test_checkJsBalance(t *testing.T) {
+	valid := []string{
+		"return val.trim()",
+		"return `${val}-${node.id}`",
+		"return `a${ {x: 1}.x }b`",
+		"let s = '({['; return s",
+		"return a / b / c",
+		"return /\\)[/]/.test(val) // )",
+		"/* ( */ return (val)",
+		"if (x) { return [1, 2] } else { return {} }",
+		"return obj.in / 2",
+	}
+	for _, code := range valid {
+		if err := checkJsBalance(code); err != nil {
+			t.Errorf("%q: unexpected error %v", code, err)
+		}
+	}
+
+	invalid := []string{
+		"return val.trim(",
+		"return val.trim())",
+		"return [1, 2}",
+		"return 'abc",
+		"return `abc",
+		"return `${val`",
+		"/* return val",
+	}
+	for _, code := range invalid {
+		if err := checkJsBalance(code); err == nil {
+			t.Errorf("%q: expected an error", code)
+		}
+	}
+}